@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	jenkinsv1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/pkg/errors"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+// The bases bugs can be classified on by summaries and the quality gate
+const (
+	basisPriority = "priority"
+	basisRank     = "rank"
+	basisBoth     = "both"
+)
+
+// analysisConfig configures how bugs are classified by summaries and the quality gate
+type analysisConfig struct {
+	// Basis is one of basisPriority, basisRank or basisBoth
+	Basis string
+	// GatePriority fails the quality gate on bugs of this priority or higher, 0 to not gate on priority
+	GatePriority int
+	// GateRank fails the quality gate on bugs of this rank or scarier, 0 to not gate on rank
+	GateRank int
+	// GateIncomplete fails the quality gate if SpotBugs couldn't analyze all code
+	GateIncomplete bool
+	// Effort and Threshold are the SpotBugs settings reports are usually produced with, recorded in the tags of
+	// summaries. Pipelines using other settings annotate their PipelineActivities with them
+	Effort    string
+	Threshold string
+	// CostRegressionFactor is how many times the usual analysis cost of a pipeline a build may take before it is
+	// reported as a regression, 0 to not report regressions
+	CostRegressionFactor float64
+}
+
+// defaultCostRegressionFactor reports builds whose analysis takes more than twice the usual time or memory
+const defaultCostRegressionFactor = 2
+
+// analysisConfigFromEnv reads the analysis configuration from the environment:
+//
+//	SPOTBUGS_BASIS         priority, rank or both; what the counts of summaries and the quality gate are based on
+//	SPOTBUGS_GATE_PRIORITY fail the quality gate on bugs of this priority or higher, e.g. high
+//	SPOTBUGS_GATE_RANK     fail the quality gate on bugs of this rank or scarier, 1-20 or a bucket, e.g. scary
+//	SPOTBUGS_GATE_INCOMPLETE
+//	                       "true" to fail the quality gate if classes were missing or the analysis had errors
+//	SPOTBUGS_COST_REGRESSION_FACTOR
+//	                       report builds whose analysis takes this many times the usual time or memory, 0 disables
+//	SPOTBUGS_EFFORT        the effort SpotBugs usually runs with, e.g. max
+//	SPOTBUGS_THRESHOLD     the threshold SpotBugs usually runs with, e.g. low
+func analysisConfigFromEnv() (analysisConfig, error) {
+	config := analysisConfig{
+		Basis:                os.Getenv("SPOTBUGS_BASIS"),
+		GateIncomplete:       os.Getenv("SPOTBUGS_GATE_INCOMPLETE") == "true",
+		Effort:               os.Getenv("SPOTBUGS_EFFORT"),
+		Threshold:            os.Getenv("SPOTBUGS_THRESHOLD"),
+		CostRegressionFactor: defaultCostRegressionFactor,
+	}
+	if config.Basis == "" {
+		config.Basis = basisPriority
+	}
+	if value := os.Getenv("SPOTBUGS_GATE_PRIORITY"); value != "" {
+		if err := config.setGatePriority(value); err != nil {
+			return config, err
+		}
+	}
+	if value := os.Getenv("SPOTBUGS_GATE_RANK"); value != "" {
+		if err := config.setGateRank(value); err != nil {
+			return config, err
+		}
+	}
+	if value := os.Getenv("SPOTBUGS_COST_REGRESSION_FACTOR"); value != "" {
+		factor, err := strconv.ParseFloat(value, 64)
+		if err != nil || factor < 0 {
+			return config, errors.Errorf("invalid cost regression factor %q", value)
+		}
+		config.CostRegressionFactor = factor
+	}
+	return config, config.validate()
+}
+
+func (c *analysisConfig) setGatePriority(value string) error {
+	priority, ok := findbugs.ParsePriority(value)
+	if !ok {
+		return errors.Errorf("invalid quality gate priority %q", value)
+	}
+	c.GatePriority = priority
+	return nil
+}
+
+func (c *analysisConfig) setGateRank(value string) error {
+	rank, ok := findbugs.ParseRank(value)
+	if !ok {
+		return errors.Errorf("invalid quality gate rank %q, must be 1-20 or one of %s", value,
+			strings.Join(findbugs.RankBuckets, ", "))
+	}
+	c.GateRank = rank
+	return nil
+}
+
+func (c analysisConfig) validate() error {
+	switch c.Basis {
+	case basisPriority:
+		if c.GateRank > 0 {
+			return errors.New("the quality gate can't use a rank when based on priority")
+		}
+	case basisRank:
+		if c.GatePriority > 0 {
+			return errors.New("the quality gate can't use a priority when based on rank")
+		}
+	case basisBoth:
+	default:
+		return errors.Errorf("invalid basis %q, must be %s, %s or %s", c.Basis, basisPriority, basisRank, basisBoth)
+	}
+	return nil
+}
+
+// forActivity returns the configuration for act, using the effort and threshold act is annotated with
+func (c analysisConfig) forActivity(act *jenkinsv1.PipelineActivity) analysisConfig {
+	if effort := act.Annotations[annotationEffort]; effort != "" {
+		c.Effort = effort
+	}
+	if threshold := act.Annotations[annotationThreshold]; threshold != "" {
+		c.Threshold = threshold
+	}
+	return c
+}
+
+// rankPriority maps the rank buckets to priorities: scariest and scary bugs are high priority, troubling ones normal
+// and those of concern low. Bugs without a rank keep their priority
+func rankPriority(b findbugs.BugInstance) int {
+	switch findbugs.RankBucket(b.Rank) {
+	case findbugs.RankScariest, findbugs.RankScary:
+		return findbugs.PriorityHigh
+	case findbugs.RankTroubling:
+		return findbugs.PriorityNormal
+	case findbugs.RankConcern:
+		return findbugs.PriorityLow
+	}
+	return b.Priority
+}
+
+// priorityOf returns the priority b is counted under by summaries. Based on both, a bug is counted under the lower of
+// its priority and the priority of its rank. Ignored bugs are always counted as ignored
+func (c analysisConfig) priorityOf(b findbugs.BugInstance) int {
+	if b.Priority == findbugs.PriorityIgnore {
+		return b.Priority
+	}
+	switch c.Basis {
+	case basisRank:
+		return rankPriority(b)
+	case basisBoth:
+		if p := rankPriority(b); p > b.Priority {
+			return p
+		}
+	}
+	return b.Priority
+}
+
+// gateEnabled returns true if a quality gate is configured
+func (c analysisConfig) gateEnabled() bool {
+	return c.GatePriority > 0 || c.GateRank > 0 || c.GateIncomplete
+}
+
+// failsGate returns true if b fails the quality gate. Based on both, and with both thresholds set, a bug has to exceed
+// both of them
+func (c analysisConfig) failsGate(b findbugs.BugInstance) bool {
+	if b.Priority == findbugs.PriorityIgnore || c.GatePriority == 0 && c.GateRank == 0 {
+		return false
+	}
+	priorityFails := c.GatePriority > 0 && b.Priority <= c.GatePriority
+	rankFails := c.GateRank > 0 && b.Rank > 0 && b.Rank <= c.GateRank
+	switch {
+	case c.GatePriority > 0 && c.GateRank > 0:
+		return priorityFails && rankFails
+	case c.GatePriority > 0:
+		return priorityFails
+	default:
+		return rankFails
+	}
+}
+
+// gateResult is the outcome of the quality gate for a report
+type gateResult struct {
+	Enabled bool
+	Failed  int
+	// Incomplete is set if the gate failed because the analysis was incomplete
+	Incomplete bool
+}
+
+func (c analysisConfig) gate(bugCollection findbugs.BugCollection) gateResult {
+	result := gateResult{
+		Enabled:    c.gateEnabled(),
+		Incomplete: c.GateIncomplete && !checkCompleteness(bugCollection).complete(),
+	}
+	for _, b := range bugCollection.BugInstance {
+		if c.failsGate(b) {
+			result.Failed++
+		}
+	}
+	return result
+}
+
+// status is passed or failed, and empty if there is no quality gate
+func (r gateResult) status() string {
+	switch {
+	case !r.Enabled:
+		return ""
+	case r.Failed > 0 || r.Incomplete:
+		return "failed"
+	default:
+		return "passed"
+	}
+}
+
+func (r gateResult) String() string {
+	switch {
+	case r.Failed > 0:
+		return fmt.Sprintf("quality gate failed, %d bugs exceed the threshold", r.Failed)
+	case r.Incomplete:
+		return "quality gate failed, the analysis is incomplete"
+	}
+	return "quality gate " + r.status()
+}
+
+// completeness is the extent of an analysis. Classes missing from the aux classpath and analysis errors stop detectors
+// from running, so the report of an incomplete analysis may look clean while it isn't
+type completeness struct {
+	MissingClasses int
+	Errors         int
+}
+
+func checkCompleteness(bugCollection findbugs.BugCollection) completeness {
+	reported := bugCollection.Errors
+	result := completeness{
+		MissingClasses: reported.MissingClasses,
+		Errors:         reported.Errors,
+	}
+	// Use the lists if the counts are missing
+	if len(reported.MissingClass) > result.MissingClasses {
+		result.MissingClasses = len(reported.MissingClass)
+	}
+	if len(reported.AnalysisError) > result.Errors {
+		result.Errors = len(reported.AnalysisError)
+	}
+	return result
+}
+
+func (c completeness) complete() bool {
+	return c.MissingClasses == 0 && c.Errors == 0
+}
+
+// status is complete or incomplete
+func (c completeness) status() string {
+	if c.complete() {
+		return "complete"
+	}
+	return "incomplete"
+}
+
+func (c completeness) String() string {
+	return fmt.Sprintf("the analysis is incomplete, %d classes were missing and there were %d errors",
+		c.MissingClasses, c.Errors)
+}
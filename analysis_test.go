@@ -0,0 +1,142 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+func TestCheckCompleteness(t *testing.T) {
+	tests := []struct {
+		name   string
+		errors findbugs.Errors
+		want   completeness
+	}{
+		{"complete", findbugs.Errors{}, completeness{}},
+		{"counts", findbugs.Errors{MissingClasses: 2, Errors: 1}, completeness{MissingClasses: 2, Errors: 1}},
+		{
+			name: "lists without counts",
+			errors: findbugs.Errors{
+				MissingClass:  []string{"com.example.Missing"},
+				AnalysisError: []findbugs.AnalysisError{{Message: "a"}, {Message: "b"}},
+			},
+			want: completeness{MissingClasses: 1, Errors: 2},
+		},
+		{
+			name:   "counts larger than the lists",
+			errors: findbugs.Errors{MissingClasses: 3, MissingClass: []string{"com.example.Missing"}},
+			want:   completeness{MissingClasses: 3},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := checkCompleteness(findbugs.BugCollection{Errors: test.errors})
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+			if got.complete() != (test.want == completeness{}) {
+				t.Errorf("got complete %t for %+v", got.complete(), got)
+			}
+		})
+	}
+}
+
+func TestFailsGate(t *testing.T) {
+	high := findbugs.BugInstance{Priority: findbugs.PriorityHigh, Rank: 15}
+	low := findbugs.BugInstance{Priority: findbugs.PriorityLow, Rank: 3}
+	ignored := findbugs.BugInstance{Priority: findbugs.PriorityIgnore, Rank: 1}
+	tests := []struct {
+		name   string
+		config analysisConfig
+		fails  []bool
+	}{
+		{"no gate", analysisConfig{}, []bool{false, false, false}},
+		{"incomplete only", analysisConfig{GateIncomplete: true}, []bool{false, false, false}},
+		{"priority", analysisConfig{GatePriority: findbugs.PriorityHigh}, []bool{true, false, false}},
+		{"rank", analysisConfig{GateRank: 4}, []bool{false, true, false}},
+		{"priority and rank", analysisConfig{GatePriority: findbugs.PriorityLow, GateRank: 10}, []bool{false, true, false}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i, b := range []findbugs.BugInstance{high, low, ignored} {
+				if got := test.config.failsGate(b); got != test.fails[i] {
+					t.Errorf("got %t for bug of priority %d and rank %d, want %t", got, b.Priority, b.Rank,
+						test.fails[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGateIncomplete(t *testing.T) {
+	missingClasses := findbugs.Errors{MissingClasses: 1, MissingClass: []string{"com.example.Missing"}}
+	analysisErrors := findbugs.Errors{Errors: 1, AnalysisError: []findbugs.AnalysisError{{Message: "failed"}}}
+	lowBug := []findbugs.BugInstance{{Priority: findbugs.PriorityLow, Rank: 18}}
+	tests := []struct {
+		name          string
+		config        analysisConfig
+		bugCollection findbugs.BugCollection
+		status        string
+		message       string
+	}{
+		{
+			name:          "gate off with missing classes",
+			config:        analysisConfig{},
+			bugCollection: findbugs.BugCollection{Errors: missingClasses},
+			status:        "",
+		},
+		{
+			name:          "incomplete gate with missing classes",
+			config:        analysisConfig{GateIncomplete: true},
+			bugCollection: findbugs.BugCollection{Errors: missingClasses},
+			status:        "failed",
+			message:       "quality gate failed, the analysis is incomplete",
+		},
+		{
+			name:          "incomplete gate with errors",
+			config:        analysisConfig{GateIncomplete: true},
+			bugCollection: findbugs.BugCollection{Errors: analysisErrors},
+			status:        "failed",
+			message:       "quality gate failed, the analysis is incomplete",
+		},
+		{
+			name:          "incomplete gate with a complete analysis",
+			config:        analysisConfig{GateIncomplete: true},
+			bugCollection: findbugs.BugCollection{BugInstance: lowBug},
+			status:        "passed",
+			message:       "quality gate passed",
+		},
+		{
+			name:          "priority gate ignores missing classes",
+			config:        analysisConfig{GatePriority: findbugs.PriorityHigh},
+			bugCollection: findbugs.BugCollection{Errors: missingClasses, BugInstance: lowBug},
+			status:        "passed",
+			message:       "quality gate passed",
+		},
+		{
+			name:          "priority and incomplete gate with errors",
+			config:        analysisConfig{GatePriority: findbugs.PriorityHigh, GateIncomplete: true},
+			bugCollection: findbugs.BugCollection{Errors: analysisErrors, BugInstance: lowBug},
+			status:        "failed",
+			message:       "quality gate failed, the analysis is incomplete",
+		},
+		{
+			name:          "failing bugs are reported before incompleteness",
+			config:        analysisConfig{GatePriority: findbugs.PriorityLow, GateIncomplete: true},
+			bugCollection: findbugs.BugCollection{Errors: missingClasses, BugInstance: lowBug},
+			status:        "failed",
+			message:       "quality gate failed, 1 bugs exceed the threshold",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := test.config.gate(test.bugCollection)
+			if got := result.status(); got != test.status {
+				t.Errorf("got status %q, want %q", got, test.status)
+			}
+			if test.message != "" && result.String() != test.message {
+				t.Errorf("got %q, want %q", result.String(), test.message)
+			}
+		})
+	}
+}
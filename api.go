@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	jenkinsv1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+	"github.com/jenkins-x/ext-spotbugs/report"
+)
+
+const activitiesPath = "/api/v1/activities/"
+
+// ActivitySummary is the representation of a summarised PipelineActivity served by the API
+type ActivitySummary struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// ReportURL is the first of ReportURLs, the URLs of the reports the summary was computed from
+	ReportURL  string   `json:"reportURL,omitempty"`
+	ReportURLs []string `json:"reportURLs,omitempty"`
+	AnalyzedAt string   `json:"analyzedAt,omitempty"`
+	// StaticProgramAnalysis is the summary as stored, including the fields jenkinsv1.StaticProgramAnalysis lacks
+	StaticProgramAnalysis json.RawMessage `json:"staticProgramAnalysis,omitempty"`
+}
+
+// apiConfig configures the read-only HTTP API
+type apiConfig struct {
+	// PrivateReports serves the findings of reports which are fetched with credentials. The API is unauthenticated, so
+	// this is only safe if it can only be reached by those allowed to read the reports
+	PrivateReports bool
+	// Authenticated returns true if the report at a URL is fetched with credentials. If it is nil no report is
+	// considered private and the findings of every report are served
+	Authenticated func(u *url.URL) bool
+	// Watched selects the namespaces whose PipelineActivities are served, those the analyzer watches
+	Watched watchConfig
+}
+
+// apiConfigFromEnv reads the API configuration from the environment:
+//
+//	SPOTBUGS_API_PRIVATE_REPORTS "true" to serve the findings of reports fetched with credentials
+func apiConfigFromEnv() apiConfig {
+	return apiConfig{PrivateReports: os.Getenv("SPOTBUGS_API_PRIVATE_REPORTS") == "true"}
+}
+
+// apiServer serves the read-only HTTP API. It only reads from the cluster, so every replica serves it regardless of
+// whether it is the leader
+type apiServer struct {
+	analyzer *analyzer
+	leader   *leaderStatus
+	config   apiConfig
+	reports  *reportCache
+}
+
+func newAPIServer(analyzer *analyzer, leader *leaderStatus, config apiConfig) http.Handler {
+	s := &apiServer{
+		analyzer: analyzer,
+		leader:   leader,
+		config:   config,
+		reports:  newReportCache(cachedReports),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.health)
+	mux.HandleFunc(activitiesPath, s.activity)
+	return mux
+}
+
+// health is used by the liveness and readiness probes
+func (s *apiServer) health(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	role := "standby"
+	if s.leader.IsLeader() {
+		role = "leader"
+	}
+	fmt.Fprintf(w, "OK (%s)\n", role)
+}
+
+// reportPath is the path the HTML report of the PipelineActivity namespace/name is served at
+func reportPath(namespace, name string) string {
+	return activitiesPath + url.PathEscape(namespace) + "/" + url.PathEscape(name) + "/report.html"
+}
+
+// activity serves the summary of the PipelineActivity at /api/v1/activities/<namespace>/<name>, its HTML report at
+// /api/v1/activities/<namespace>/<name>/report.html, its findings at .../findings.csv and .../findings.jsonl and its
+// hotspots and analysis performance at .../hotspots and .../performance
+func (s *apiServer) activity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, activitiesPath), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" || !s.served(parts[0]) {
+		http.NotFound(w, r)
+		return
+	}
+	act, summary, err := s.getActivity(parts[0], parts[1])
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("Error retrieving PipelineActivity %s/%s: %v\n", parts[0], parts[1], err)
+		http.Error(w, "unable to retrieve activity", http.StatusBadGateway)
+		return
+	}
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "report.html":
+			s.htmlReport(w, act)
+		case "findings.csv":
+			s.export(w, act, report.CSVMimeType, report.WriteCSV)
+		case "findings.jsonl":
+			s.export(w, act, report.JSONLinesMimeType, report.WriteJSONLines)
+		case "hotspots":
+			s.hotspots(w, r, act)
+		case "performance":
+			s.performance(w, act)
+		default:
+			http.NotFound(w, r)
+		}
+		return
+	}
+	reportURLs := strings.Fields(act.Annotations[annotationReportURL])
+	reportURL := ""
+	if len(reportURLs) > 0 {
+		reportURL = reportURLs[0]
+	}
+	writeJSON(w, ActivitySummary{
+		Namespace:             act.Namespace,
+		Name:                  act.Name,
+		ReportURL:             reportURL,
+		ReportURLs:            reportURLs,
+		AnalyzedAt:            act.Annotations[annotationAnalyzedAt],
+		StaticProgramAnalysis: summary,
+	})
+}
+
+// served returns true if the PipelineActivities of namespace are served, which are only those of the watched
+// namespaces
+func (s *apiServer) served(namespace string) bool {
+	if s.config.Watched.AllNamespaces {
+		return true
+	}
+	for _, watched := range s.config.Watched.Namespaces {
+		if namespace == watched {
+			return true
+		}
+	}
+	return false
+}
+
+// getActivity retrieves the PipelineActivity namespace/name along with its summary as stored. The summary is read from
+// the raw object, as decoding it into jenkinsv1.StaticProgramAnalysis would drop the mime type of the original report
+func (s *apiServer) getActivity(namespace, name string) (*jenkinsv1.PipelineActivity, json.RawMessage, error) {
+	data, err := s.analyzer.client.RESTClient().Get().
+		Namespace(namespace).
+		Resource("pipelineactivities").
+		Name(name).
+		DoRaw()
+	if err != nil {
+		return nil, nil, err
+	}
+	act := &jenkinsv1.PipelineActivity{}
+	if err := json.Unmarshal(data, act); err != nil {
+		return nil, nil, err
+	}
+	var raw struct {
+		Spec struct {
+			Summaries struct {
+				StaticProgramAnalysis json.RawMessage `json:"staticProgramAnalysis"`
+			} `json:"summaries"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, err
+	}
+	return act, raw.Spec.Summaries.StaticProgramAnalysis, nil
+}
+
+// htmlReport renders the SpotBugs report the summary of act was computed from as HTML
+func (s *apiServer) htmlReport(w http.ResponseWriter, act *jenkinsv1.PipelineActivity) {
+	bugCollection, ok := s.report(w, act)
+	if !ok {
+		return
+	}
+	var buf bytes.Buffer
+	title := fmt.Sprintf("SpotBugs report for %s", act.Name)
+	if err := report.WriteHTML(&buf, bugCollection, title); err != nil {
+		log.Printf("Error rendering report for PipelineActivity %s/%s: %v\n", act.Namespace, act.Name, err)
+		http.Error(w, "unable to render report", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", report.HTMLMimeType+"; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// defaultHotspots is the number of packages and classes served in each ranking of hotspots, unless the top query
+// parameter asks for a different number
+const defaultHotspots = 10
+
+// hotspots serves the packages and classes with the most bugs in the SpotBugs report the summary of act was computed
+// from
+func (s *apiServer) hotspots(w http.ResponseWriter, r *http.Request, act *jenkinsv1.PipelineActivity) {
+	n := defaultHotspots
+	if value := r.URL.Query().Get("top"); value != "" {
+		var err error
+		n, err = strconv.Atoi(value)
+		if err != nil || n < 1 {
+			http.Error(w, "top must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+	bugCollection, ok := s.report(w, act)
+	if !ok {
+		return
+	}
+	writeJSON(w, report.FindHotspots(bugCollection, n))
+}
+
+// slowestDetectors is the number of detectors served by performance
+const slowestDetectors = 10
+
+// performance serves the cost of the analysis which produced the SpotBugs report the summary of act was computed from
+func (s *apiServer) performance(w http.ResponseWriter, act *jenkinsv1.PipelineActivity) {
+	bugCollection, ok := s.report(w, act)
+	if !ok {
+		return
+	}
+	writeJSON(w, report.NewPerformance(bugCollection, slowestDetectors))
+}
+
+// export writes the findings of the SpotBugs report the summary of act was computed from as flat rows
+func (s *apiServer) export(w http.ResponseWriter, act *jenkinsv1.PipelineActivity, contentType string,
+	write func(io.Writer, report.ExportContext, []report.Finding) error) {
+	bugCollection, ok := s.report(w, act)
+	if !ok {
+		return
+	}
+	repository := act.Spec.GitRepository
+	if act.Spec.GitOwner != "" {
+		repository = act.Spec.GitOwner + "/" + repository
+	}
+	context := report.ExportContext{
+		Repository: repository,
+		Build:      act.Spec.Build,
+		Commit:     act.Spec.LastCommitSHA,
+	}
+	var buf bytes.Buffer
+	if err := write(&buf, context, report.Findings(bugCollection.BugInstance)); err != nil {
+		log.Printf("Error exporting findings of PipelineActivity %s/%s: %v\n", act.Namespace, act.Name, err)
+		http.Error(w, "unable to export findings", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// report retrieves and merges the SpotBugs reports the summary of act was computed from and applies the filters of
+// act to them, writing an error response if it can't. Reports fetched with credentials are withheld unless
+// PrivateReports is set. The result is cached for the version of act, so the pages of an activity don't read its
+// reports again
+func (s *apiServer) report(w http.ResponseWriter, act *jenkinsv1.PipelineActivity) (findbugs.BugCollection, bool) {
+	reportURLs := strings.Fields(act.Annotations[annotationReportURL])
+	if len(reportURLs) == 0 {
+		http.Error(w, "activity has not been analyzed", http.StatusNotFound)
+		return findbugs.BugCollection{}, false
+	}
+	if !s.config.PrivateReports && s.config.Authenticated != nil {
+		for _, reportURL := range reportURLs {
+			if u, err := url.Parse(reportURL); err == nil && s.config.Authenticated(u) {
+				http.Error(w, "the reports of the activity are private", http.StatusForbidden)
+				return findbugs.BugCollection{}, false
+			}
+		}
+	}
+	filters, err := s.analyzer.filters.forActivity(act)
+	if err != nil {
+		log.Printf("Error filtering the reports of PipelineActivity %s/%s: %v\n", act.Namespace, act.Name, err)
+		http.Error(w, "invalid filters", http.StatusInternalServerError)
+		return findbugs.BugCollection{}, false
+	}
+	key := activityKey(act) + "@" + act.ResourceVersion
+	bugCollection, err := s.reports.get(key, func() (findbugs.BugCollection, error) {
+		bugCollection, err := parseSpotBugsReports(reportURLs, s.analyzer.fetcher, s.analyzer.maxSize)
+		if err != nil {
+			return bugCollection, err
+		}
+		return filters.apply(bugCollection, s.analyzer.fetcher)
+	})
+	if err != nil {
+		log.Printf("Error retrieving the reports of PipelineActivity %s/%s: %v\n", act.Namespace, act.Name, err)
+		http.Error(w, "unable to retrieve report", http.StatusBadGateway)
+		return findbugs.BugCollection{}, false
+	}
+	return bugCollection, true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("Error writing response: %v\n", err)
+	}
+}
+
+// cachedReports is the number of filtered reports kept by the API, see fetch.maxSize in the chart for the memory needed
+const cachedReports = 4
+
+// reportCache keeps the filtered reports of the activities last served by the API. Requests for an activity whose
+// reports are being read wait for them instead of reading them again, reports which couldn't be read aren't kept
+type reportCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	// lru orders the reports from the most to the least recently used
+	lru *list.List
+}
+
+type cachedReport struct {
+	key string
+	// done is closed once bugCollection and err are set
+	done          chan struct{}
+	bugCollection findbugs.BugCollection
+	err           error
+}
+
+func newReportCache(size int) *reportCache {
+	return &reportCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// get returns the report cached for key, calling load to read it if there is none
+func (c *reportCache) get(key string, load func() (findbugs.BugCollection, error)) (findbugs.BugCollection, error) {
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(element)
+		c.mu.Unlock()
+		cached := element.Value.(*cachedReport)
+		<-cached.done
+		return cached.bugCollection, cached.err
+	}
+	cached := &cachedReport{key: key, done: make(chan struct{})}
+	c.entries[key] = c.lru.PushFront(cached)
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+	c.mu.Unlock()
+
+	cached.bugCollection, cached.err = load()
+	close(cached.done)
+	if cached.err != nil {
+		c.mu.Lock()
+		if element, ok := c.entries[key]; ok && element.Value == cached {
+			c.remove(element)
+		}
+		c.mu.Unlock()
+	}
+	return cached.bugCollection, cached.err
+}
+
+func (c *reportCache) remove(element *list.Element) {
+	delete(c.entries, element.Value.(*cachedReport).key)
+	c.lru.Remove(element)
+}
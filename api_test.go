@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	jenkinsv1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+	"github.com/jenkins-x/ext-spotbugs/report"
+)
+
+const sampleReportURL = "https://reports.example.com/demo/1/spotbugsXml.xml"
+
+func newTestAPIServer(t *testing.T) *httptest.Server {
+	return newTestAPIServerWithConfig(t, apiConfig{})
+}
+
+func newTestAPIServerWithConfig(t *testing.T, config apiConfig) *httptest.Server {
+	server, _ := newTestAPIServerWithFetcher(t, config)
+	return server
+}
+
+// newTestAPIServerWithFetcher serves the activity jx/demo-1 with config, which watches the jx namespace unless it
+// selects the watched namespaces
+func newTestAPIServerWithFetcher(t *testing.T, config apiConfig) (*httptest.Server, *fileFetcher) {
+	if !config.Watched.AllNamespaces && len(config.Watched.Namespaces) == 0 {
+		config.Watched.Namespaces = []string{"jx"}
+	}
+	act := &jenkinsv1.PipelineActivity{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "jx",
+			Name:      "demo-1",
+			Annotations: map[string]string{
+				annotationReportURL: sampleReportURL,
+			},
+		},
+		Spec: jenkinsv1.PipelineActivitySpec{
+			GitOwner:      "example",
+			GitRepository: "demo",
+			Build:         "1",
+			LastCommitSHA: "abc123",
+		},
+	}
+	_, client := newFakeCluster(t, act)
+	fetcher := &fileFetcher{files: map[string]string{sampleReportURL: sampleReport}}
+	a := newAnalyzer(client, fetcher, 0, "", analysisConfig{Basis: basisPriority}, filterConfig{})
+	server := httptest.NewServer(newAPIServer(a, &leaderStatus{}, config))
+	t.Cleanup(server.Close)
+	return server, fetcher
+}
+
+func TestAPIActivity(t *testing.T) {
+	server := newTestAPIServer(t)
+	tests := []struct {
+		path        string
+		status      int
+		contentType string
+		check       func(t *testing.T, body string)
+	}{
+		{
+			path:        "/api/v1/activities/jx/demo-1",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			check: func(t *testing.T, body string) {
+				var summary ActivitySummary
+				if err := json.Unmarshal([]byte(body), &summary); err != nil {
+					t.Fatal(err)
+				}
+				if summary.ReportURL != sampleReportURL {
+					t.Errorf("got report URL %q, want %q", summary.ReportURL, sampleReportURL)
+				}
+			},
+		},
+		{
+			path:        "/api/v1/activities/jx/demo-1/findings.csv",
+			status:      http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			check: func(t *testing.T, body string) {
+				lines := strings.Split(strings.TrimSpace(body), "\n")
+				if len(lines) != 6 {
+					t.Fatalf("got %d lines, want a header and 5 findings:\n%s", len(lines), body)
+				}
+				if !strings.HasPrefix(lines[0], "repository,build,commit,") {
+					t.Errorf("got header %q", lines[0])
+				}
+				if !strings.HasPrefix(lines[1], "example/demo,1,abc123,") {
+					t.Errorf("got row %q, want the repository, build and commit of the activity", lines[1])
+				}
+			},
+		},
+		{
+			path:        "/api/v1/activities/jx/demo-1/findings.jsonl",
+			status:      http.StatusOK,
+			contentType: "application/x-ndjson; charset=utf-8",
+			check: func(t *testing.T, body string) {
+				lines := strings.Split(strings.TrimSpace(body), "\n")
+				if len(lines) != 5 {
+					t.Fatalf("got %d lines, want 5 findings:\n%s", len(lines), body)
+				}
+				for _, line := range lines {
+					var row map[string]interface{}
+					if err := json.Unmarshal([]byte(line), &row); err != nil {
+						t.Fatalf("invalid JSON line %q: %v", line, err)
+					}
+					if row["repository"] != "example/demo" {
+						t.Errorf("got repository %v", row["repository"])
+					}
+				}
+			},
+		},
+		{
+			path:        "/api/v1/activities/jx/demo-1/hotspots?top=1",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			check: func(t *testing.T, body string) {
+				var hotspots report.Hotspots
+				if err := json.Unmarshal([]byte(body), &hotspots); err != nil {
+					t.Fatal(err)
+				}
+				if len(hotspots.PackagesByBugs) != 1 || len(hotspots.ClassesByBugs) != 1 {
+					t.Fatalf("got %+v, want the top package and class", hotspots)
+				}
+				if top := hotspots.ClassesByBugs[0]; top.Bugs != 2 {
+					t.Errorf("got top class %+v, want one with 2 bugs", top)
+				}
+			},
+		},
+		{
+			path:   "/api/v1/activities/jx/demo-1/hotspots?top=0",
+			status: http.StatusBadRequest,
+		},
+		{
+			path:        "/api/v1/activities/jx/demo-1/performance",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			check: func(t *testing.T, body string) {
+				var performance report.Performance
+				if err := json.Unmarshal([]byte(body), &performance); err != nil {
+					t.Fatal(err)
+				}
+				if performance.CPUSeconds != 21.5 || performance.PeakMBytes < 310 || performance.PeakMBytes > 311 {
+					t.Errorf("got %+v, want the cost recorded in the report", performance)
+				}
+				if len(performance.SlowestDetectors) == 0 ||
+					performance.SlowestDetectors[0].Name != "edu.umd.cs.findbugs.detect.FindNullDeref" {
+					t.Errorf("got detectors %+v, want FindNullDeref first", performance.SlowestDetectors)
+				}
+			},
+		},
+		{
+			path:   "/api/v1/activities/jx/demo-1/findings.xml",
+			status: http.StatusNotFound,
+		},
+		{
+			path:   "/api/v1/activities/jx/missing/findings.csv",
+			status: http.StatusNotFound,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			response, err := http.Get(server.URL + test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+			body, err := ioutil.ReadAll(response.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.StatusCode != test.status {
+				t.Fatalf("got status %d, want %d: %s", response.StatusCode, test.status, body)
+			}
+			if test.contentType != "" && response.Header.Get("Content-Type") != test.contentType {
+				t.Errorf("got content type %q, want %q", response.Header.Get("Content-Type"), test.contentType)
+			}
+			if test.check != nil {
+				test.check(t, string(body))
+			}
+		})
+	}
+}
+
+func TestAPIWithholdsPrivateReports(t *testing.T) {
+	authenticated := func(u *url.URL) bool {
+		return u.Host == "reports.example.com"
+	}
+	tests := []struct {
+		config apiConfig
+		path   string
+		status int
+	}{
+		{apiConfig{Authenticated: authenticated}, "/api/v1/activities/jx/demo-1/findings.csv", http.StatusForbidden},
+		{apiConfig{Authenticated: authenticated}, "/api/v1/activities/jx/demo-1/report.html", http.StatusForbidden},
+		// The summary only holds counts
+		{apiConfig{Authenticated: authenticated}, "/api/v1/activities/jx/demo-1", http.StatusOK},
+		{apiConfig{Authenticated: authenticated, PrivateReports: true}, "/api/v1/activities/jx/demo-1/findings.csv",
+			http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			server := newTestAPIServerWithConfig(t, test.config)
+			response, err := http.Get(server.URL + test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+			if response.StatusCode != test.status {
+				t.Errorf("got status %d, want %d", response.StatusCode, test.status)
+			}
+		})
+	}
+}
+
+func TestAPICachesReports(t *testing.T) {
+	server, fetcher := newTestAPIServerWithFetcher(t, apiConfig{})
+	for _, path := range []string{"report.html", "findings.csv", "hotspots", "report.html"} {
+		response, err := http.Get(server.URL + "/api/v1/activities/jx/demo-1/" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("got status %d for %s, want %d", response.StatusCode, path, http.StatusOK)
+		}
+	}
+	fetcher.mu.Lock()
+	defer fetcher.mu.Unlock()
+	if fetcher.fetches != 1 {
+		t.Errorf("got %d fetches, want the report to be fetched once for every page of the activity", fetcher.fetches)
+	}
+}
+
+func TestAPIOnlyServesWatchedNamespaces(t *testing.T) {
+	tests := []struct {
+		watched watchConfig
+		status  int
+	}{
+		{watchConfig{Namespaces: []string{"jx"}}, http.StatusOK},
+		{watchConfig{Namespaces: []string{"jx-staging", "jx"}}, http.StatusOK},
+		{watchConfig{Namespaces: []string{"jx-staging"}}, http.StatusNotFound},
+		{watchConfig{AllNamespaces: true}, http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.watched), func(t *testing.T) {
+			server := newTestAPIServerWithConfig(t, apiConfig{Watched: test.watched})
+			for _, path := range []string{"/api/v1/activities/jx/demo-1", "/api/v1/activities/jx/demo-1/findings.csv"} {
+				response, err := http.Get(server.URL + path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				response.Body.Close()
+				if response.StatusCode != test.status {
+					t.Errorf("got status %d for %s, want %d", response.StatusCode, path, test.status)
+				}
+			}
+		})
+	}
+}
+
+func TestReportCache(t *testing.T) {
+	cache := newReportCache(2)
+	loads := 0
+	load := func(bugs int, err error) func() (findbugs.BugCollection, error) {
+		return func() (findbugs.BugCollection, error) {
+			loads++
+			return findbugs.BugCollection{BugInstance: make([]findbugs.BugInstance, bugs)}, err
+		}
+	}
+	get := func(key string, bugs int, err error) findbugs.BugCollection {
+		bugCollection, _ := cache.get(key, load(bugs, err))
+		return bugCollection
+	}
+
+	get("jx/demo-1@1", 1, nil)
+	if got := get("jx/demo-1@1", 2, nil); len(got.BugInstance) != 1 || loads != 1 {
+		t.Errorf("got %d bugs after %d loads, want the cached report", len(got.BugInstance), loads)
+	}
+	// A failed load isn't kept
+	get("jx/demo-2@1", 0, errors.New("unreachable"))
+	if got := get("jx/demo-2@1", 2, nil); len(got.BugInstance) != 2 || loads != 3 {
+		t.Errorf("got %d bugs after %d loads, want the report to be loaded again", len(got.BugInstance), loads)
+	}
+	// The least recently used report is evicted
+	get("jx/demo-1@1", 1, nil)
+	get("jx/demo-3@1", 3, nil)
+	if loads != 4 {
+		t.Errorf("got %d loads, want 4", loads)
+	}
+	get("jx/demo-2@1", 2, nil)
+	if loads != 5 {
+		t.Errorf("got %d loads, want the least recently used report to be loaded again", loads)
+	}
+}
@@ -14,3 +14,17 @@ We truncate at 63 chars because some Kubernetes name fields are limited to this
 {{- $name := default .Chart.Name .Values.nameOverride -}}
 {{- printf "%s-%s" .Release.Name $name | trunc 63 | trimSuffix "-" -}}
 {{- end -}}
+
+{{/*
+The ServiceAccount the analyzer runs as, which the Roles of the chart are bound to.
+*/}}
+{{- define "serviceAccountName" -}}
+{{- default "default" .Values.serviceAccountName -}}
+{{- end -}}
+
+{{/*
+The name of the Lease used for leader election.
+*/}}
+{{- define "leaseName" -}}
+{{- default (include "fullname" .) .Values.leaderElection.leaseName -}}
+{{- end -}}
@@ -0,0 +1,563 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+
+	"github.com/jenkins-x/ext-spotbugs/fetch"
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+	"github.com/jenkins-x/ext-spotbugs/report"
+)
+
+// command is a subcommand of the ext-spotbugs binary which works on reports directly, without a cluster
+type command struct {
+	name        string
+	args        string
+	description string
+	run         func(args []string, out io.Writer) error
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{
+			name:        "analyze",
+			args:        "<file|url>",
+			description: "Print the summary which would be written to the PipelineActivity",
+			run:         runAnalyze,
+		},
+		{
+			name:        "diff",
+			args:        "<base> <head>",
+			description: "Show the bugs introduced and fixed between two reports",
+			run:         runDiff,
+		},
+		{
+			name:        "list",
+			args:        "<file|url>",
+			description: "List the individual bugs of a report",
+			run:         runList,
+		},
+		{
+			name:        "report",
+			args:        "<file|url>",
+			description: "Render a report describing every bug as HTML, Markdown, JUnit XML or GitLab Code Quality or SonarQube issues",
+			run:         runReport,
+		},
+	}
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage:\n  %s [flags]\t\twatch PipelineActivities and summarise their SpotBugs reports\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %s %s %s [flags]\t%s\n", os.Args[0], cmd.name, cmd.args, cmd.description)
+	}
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+// lineFormats render findings one per line, so that editors and CI systems can link to the offending lines
+var lineFormats = map[string]func(io.Writer, []report.Finding) error{
+	"compiler": report.WriteCompiler,
+	"github":   report.WriteGitHub,
+}
+
+// exportFormats render findings as flat rows, for spreadsheets and log pipelines
+var exportFormats = map[string]func(io.Writer, report.ExportContext, []report.Finding) error{
+	"csv":   report.WriteCSV,
+	"jsonl": report.WriteJSONLines,
+}
+
+// newFlagSet creates the flags of cmd, including the output format which may be one of formats, defaulting to the first
+func (cmd *command) newFlagSet(output *string, formats ...string) *flag.FlagSet {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s %s %s [flags]\n\n%s\n\nFlags:\n", os.Args[0], cmd.name, cmd.args,
+			cmd.description)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(output, "o", formats[0], "Output format, one of "+strings.Join(formats, ", "))
+	return flags
+}
+
+// parseArgs parses args, which may have flags before and after the n positional arguments
+func parseArgs(flags *flag.FlagSet, args []string, n int) ([]string, error) {
+	var positional []string
+	for {
+		err := flags.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(positional) != n {
+		flags.Usage()
+		return nil, errors.Errorf("expected %d argument(s) but got %d", n, len(positional))
+	}
+	return positional, nil
+}
+
+// runAnalyze summarises a report like the analyzer does, using the same SPOTBUGS_* analysis and filter configuration
+// from the environment, which the flags override
+func runAnalyze(args []string, out io.Writer) error {
+	var output, gatePriority, gateRank string
+	var flagFilters filterConfig
+	config, err := analysisConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	filters := filterConfigFromEnv()
+	flags := findCommand("analyze").newFlagSet(&output, "table", "json", "yaml")
+	flags.StringVar(&config.Basis, "basis", config.Basis, "Count bugs by priority, rank or both")
+	flags.StringVar(&gatePriority, "gate-priority", "", "Fail if there are bugs of this priority or higher, e.g. high")
+	flags.StringVar(&gateRank, "gate-rank", "", "Fail if there are bugs of this rank or scarier, 1-20 or a bucket, e.g. scary")
+	flags.StringVar(&config.Effort, "effort", config.Effort, "The effort SpotBugs ran with, recorded in the tags")
+	flags.StringVar(&config.Threshold, "threshold", config.Threshold, "The threshold SpotBugs ran with, recorded in the tags")
+	flags.BoolVar(&config.GateIncomplete, "gate-incomplete", config.GateIncomplete, "Fail if classes were missing or the analysis had errors")
+	addFilterFlags(flags, &flagFilters)
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if len(flagFilters.Include) > 0 {
+		filters.Include = flagFilters.Include
+	}
+	if len(flagFilters.Exclude) > 0 {
+		filters.Exclude = flagFilters.Exclude
+	}
+	if gatePriority != "" {
+		if err := config.setGatePriority(gatePriority); err != nil {
+			return err
+		}
+	}
+	if gateRank != "" {
+		if err := config.setGateRank(gateRank); err != nil {
+			return err
+		}
+	}
+	if err := config.validate(); err != nil {
+		return err
+	}
+	raw, err := loadReport(positional[0])
+	if err != nil {
+		return err
+	}
+	for _, warning := range checkTotals(raw) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	if completeness := checkCompleteness(raw); !completeness.complete() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", completeness)
+	}
+	bugCollection, err := filterReport(raw, filters)
+	if err != nil {
+		return err
+	}
+	summary := summarise(bugCollection, config)
+	if !filters.empty() {
+		summary.filtered(summarise(raw, config))
+	}
+	gate := config.gate(bugCollection)
+	summary.gated(gate)
+	summary.Original = originalReport(bugCollection)
+	err = render(out, output, summary, func(w io.Writer) {
+		writeSummaryTable(w, summary)
+	})
+	if err != nil {
+		return err
+	}
+	if gate.status() == "failed" {
+		return errors.New(gate.String())
+	}
+	return nil
+}
+
+func runDiff(args []string, out io.Writer) error {
+	var output, sourceRoot string
+	var filters filterConfig
+	flags := findCommand("diff").newFlagSet(&output, "table", "json", "yaml", "compiler", "github")
+	flags.StringVar(&sourceRoot, "source-root", "", "Directory the source paths in the report are relative to, e.g. src/main/java")
+	addFilterFlags(flags, &filters)
+	positional, err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+	base, err := loadFilteredReport(positional[0], filters)
+	if err != nil {
+		return err
+	}
+	head, err := loadFilteredReport(positional[1], filters)
+	if err != nil {
+		return err
+	}
+	diff := findbugs.Compare(base, head)
+	if write, ok := lineFormats[output]; ok {
+		// Only new bugs can be pointed at in the head sources
+		return write(out, withSourceRoot(report.Findings(diff.New), sourceRoot))
+	}
+	result := struct {
+		New   []report.Finding `json:"new"`
+		Fixed []report.Finding `json:"fixed"`
+	}{
+		New:   report.Findings(diff.New),
+		Fixed: report.Findings(diff.Fixed),
+	}
+	return render(out, output, result, func(w io.Writer) {
+		fmt.Fprintf(w, "%d new, %d fixed\n\n", len(result.New), len(result.Fixed))
+		fmt.Fprintln(w, "STATUS\tPRIORITY\tRANK\tCATEGORY\tPATTERN\tCLASS\tLINE")
+		for _, f := range result.New {
+			writeFindingRow(w, "new", f)
+		}
+		for _, f := range result.Fixed {
+			writeFindingRow(w, "fixed", f)
+		}
+	})
+}
+
+func runList(args []string, out io.Writer) error {
+	var output, sourceRoot string
+	var filters filterConfig
+	query := bugQuery{}
+	context := report.ExportContext{}
+	flags := findCommand("list").newFlagSet(&output, "table", "json", "yaml", "compiler", "github", "csv", "jsonl")
+	flags.StringVar(&sourceRoot, "source-root", "", "Directory the source paths in the report are relative to, e.g. src/main/java")
+	flags.StringVar(&context.Repository, "repo", "", "The repository the report was produced for, added to csv and jsonl rows")
+	flags.StringVar(&context.Build, "build", "", "The build the report was produced by, added to csv and jsonl rows")
+	flags.StringVar(&context.Commit, "commit", "", "The commit the report was produced for, added to csv and jsonl rows")
+	flags.Var(&query.priorities, "priority", "Only list bugs of these priorities (high, normal, low, experimental, ignored or 1-5), comma separated")
+	flags.Var(&query.categories, "category", "Only list bugs in these categories, comma separated")
+	flags.Var(&query.patterns, "pattern", "Only list bugs of these patterns, comma separated. Accepts * wildcards, e.g. NP_*")
+	flags.Var(&query.packages, "package", "Only list bugs in these packages or their subpackages, comma separated")
+	addFilterFlags(flags, &filters)
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	bugCollection, err := loadFilteredReport(positional[0], filters)
+	if err != nil {
+		return err
+	}
+	if err := query.validate(); err != nil {
+		return err
+	}
+	findings := make([]report.Finding, 0)
+	for _, b := range bugCollection.BugInstance {
+		if query.matches(b) {
+			findings = append(findings, report.NewFinding(b))
+		}
+	}
+	if write, ok := lineFormats[output]; ok {
+		return write(out, withSourceRoot(findings, sourceRoot))
+	}
+	if write, ok := exportFormats[output]; ok {
+		return write(out, context, withSourceRoot(findings, sourceRoot))
+	}
+	return render(out, output, findings, func(w io.Writer) {
+		fmt.Fprintln(w, "PRIORITY\tRANK\tCATEGORY\tPATTERN\tCLASS\tLINE")
+		for _, f := range findings {
+			writeFindingRow(w, "", f)
+		}
+	})
+}
+
+func runReport(args []string, out io.Writer) error {
+	var output, title, base, groupBy, sourceRoot string
+	var maxBugs int
+	var efforts listFlag
+	var filters filterConfig
+	flags := findCommand("report").newFlagSet(&output, "html", "markdown", "junit", "codeclimate", "sonar")
+	flags.StringVar(&title, "title", "SpotBugs report", "The title of the report")
+	flags.StringVar(&base, "base", "", "A report to compare with, listing the new and fixed bugs in markdown output")
+	flags.IntVar(&maxBugs, "max-bugs", 50, "The maximum number of new and fixed bugs listed in markdown output, 0 for no limit")
+	flags.StringVar(&groupBy, "group-by", report.JUnitByPackage, "Group the testcases of junit output by package or category")
+	flags.StringVar(&sourceRoot, "source-root", "", "Directory the source paths in the report are relative to, e.g. src/main/java")
+	flags.Var(&efforts, "effort", "Minutes needed to fix a bug of a pattern in sonar output as PATTERN=MINUTES, comma separated")
+	addFilterFlags(flags, &filters)
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	effort, err := parseEfforts(efforts)
+	if err != nil {
+		return err
+	}
+	bugCollection, err := loadFilteredReport(positional[0], filters)
+	if err != nil {
+		return err
+	}
+	switch output {
+	case "html":
+		return report.WriteHTML(out, bugCollection, title)
+	case "markdown":
+		options := report.MarkdownOptions{Title: title, MaxBugs: maxBugs}
+		if base != "" {
+			baseCollection, err := loadFilteredReport(base, filters)
+			if err != nil {
+				return err
+			}
+			diff := findbugs.Compare(baseCollection, bugCollection)
+			options.Diff = &diff
+		}
+		return report.WriteMarkdown(out, bugCollection, options)
+	case "junit":
+		return report.WriteJUnit(out, bugCollection, groupBy)
+	case "codeclimate":
+		return report.WriteCodeClimate(out, bugCollection, sourceRoot)
+	case "sonar":
+		return report.WriteSonar(out, bugCollection, report.SonarOptions{SourceRoot: sourceRoot, Effort: effort})
+	default:
+		return errors.Errorf("unknown output format %s", output)
+	}
+}
+
+// parseEfforts parses PATTERN=MINUTES values
+func parseEfforts(values []string) (map[string]int, error) {
+	result := make(map[string]int)
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid effort %s, must be PATTERN=MINUTES", value)
+		}
+		minutes, err := strconv.Atoi(parts[1])
+		if err != nil || minutes < 0 {
+			return nil, errors.Errorf("invalid effort %s, must be PATTERN=MINUTES", value)
+		}
+		result[strings.TrimSpace(parts[0])] = minutes
+	}
+	return result, nil
+}
+
+// loadReport reads the report at location, which is either a URL of a scheme supported by the fetchers, a file or -
+// for stdin. The report may be compressed or an archive of reports
+func loadReport(location string) (findbugs.BugCollection, error) {
+	var bugCollection findbugs.BugCollection
+	var err error
+	switch {
+	case location == "-":
+		bugCollection, err = parseReports("stdin", os.Stdin)
+	case strings.Contains(location, "://"):
+		var config fetch.Config
+		config, err = cliFetchConfig()
+		if err != nil {
+			break
+		}
+		bugCollection, err = parseSpotBugsReport(location, fetch.New(config), config.Policy.MaxSize)
+	default:
+		var f *os.File
+		f, err = os.Open(location)
+		if err != nil {
+			break
+		}
+		bugCollection, err = parseReports(location, f)
+		f.Close()
+	}
+	return bugCollection, errors.Wrapf(err, "unable to read report %s", location)
+}
+
+// newCLIFetcher creates the fetchers URLs given to the CLI are read with. They are configured from the environment like
+// those of the analyzer, except that file:// URLs may point anywhere and private addresses are allowed
+func newCLIFetcher() (fetch.ReportFetcher, error) {
+	config, err := cliFetchConfig()
+	if err != nil {
+		return nil, err
+	}
+	return fetch.New(config), nil
+}
+
+// cliFetchConfig returns the configuration of the fetchers created by newCLIFetcher
+func cliFetchConfig() (fetch.Config, error) {
+	config, err := fetch.ConfigFromEnv()
+	if err != nil {
+		return config, err
+	}
+	config.FileRoot = "/"
+	config.Policy.AllowPrivate = true
+	return config, nil
+}
+
+// addFilterFlags adds the flags selecting the SpotBugs filters applied to reports to flags
+func addFilterFlags(flags *flag.FlagSet, filters *filterConfig) {
+	flags.Var((*listFlag)(&filters.Include), "include", "Only keep the bugs matching one of these SpotBugs filter files or URLs, comma separated")
+	flags.Var((*listFlag)(&filters.Exclude), "exclude", "Suppress the bugs matching one of these SpotBugs filter files or URLs, comma separated")
+}
+
+// filterReport applies filters to bugCollection
+func filterReport(bugCollection findbugs.BugCollection, filters filterConfig) (findbugs.BugCollection, error) {
+	if filters.empty() {
+		return bugCollection, nil
+	}
+	fetcher, err := newCLIFetcher()
+	if err != nil {
+		return bugCollection, err
+	}
+	return filters.apply(bugCollection, fetcher)
+}
+
+// loadFilteredReport reads the report at location like loadReport and applies filters to it
+func loadFilteredReport(location string, filters filterConfig) (findbugs.BugCollection, error) {
+	bugCollection, err := loadReport(location)
+	if err != nil {
+		return bugCollection, err
+	}
+	return filterReport(bugCollection, filters)
+}
+
+// withSourceRoot prefixes the source paths of findings with root
+func withSourceRoot(findings []report.Finding, root string) []report.Finding {
+	if root == "" {
+		return findings
+	}
+	for i := range findings {
+		if findings[i].File != "" {
+			findings[i].File = path.Join(root, findings[i].File)
+		}
+	}
+	return findings
+}
+
+// render writes v to out as json or yaml, or calls table to write it as a table
+func render(out io.Writer, format string, v interface{}, table func(w io.Writer)) error {
+	switch format {
+	case "table":
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		table(w)
+		return w.Flush()
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case "yaml":
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(data)
+		return err
+	default:
+		return errors.Errorf("unknown output format %s", format)
+	}
+}
+
+func writeSummaryTable(w io.Writer, summary analysisSummary) {
+	fmt.Fprintln(w, "CATEGORY\tHIGH\tNORMAL\tLOW\tEXPERIMENTAL\tIGNORED")
+	names := make([]string, 0, len(summary.Categories))
+	for name := range summary.Categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := summary.Categories[name]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", name, c.HighPriority, c.NormalPriority, c.LowPriority,
+			summary.CategoryExperimentalPriority[name], c.Ignored)
+	}
+	fmt.Fprintf(w, "TOTAL\t%d\t%d\t%d\t%d\t%d\n", summary.HighPriority, summary.NormalPriority, summary.LowPriority,
+		summary.ExperimentalPriority, summary.Ignored)
+	fmt.Fprintf(w, "\n%d bugs in %d classes\n", summary.TotalBugs, summary.TotalClasses)
+	for _, tag := range summary.Tags {
+		fmt.Fprintln(w, tag)
+	}
+}
+
+func writeFindingRow(w io.Writer, status string, f report.Finding) {
+	location := f.Class
+	if f.Method != "" {
+		location += "." + f.Method
+	} else if f.Field != "" {
+		location += "." + f.Field
+	}
+	line := ""
+	if f.Line > 0 {
+		line = fmt.Sprintf("%d", f.Line)
+	}
+	if status != "" {
+		fmt.Fprintf(w, "%s\t", status)
+	}
+	fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", f.Priority, f.Rank, f.Category, f.Pattern, location, line)
+}
+
+// bugQuery selects bugs for the list command, empty criteria match every bug
+type bugQuery struct {
+	priorities listFlag
+	categories listFlag
+	patterns   listFlag
+	packages   listFlag
+}
+
+func (q bugQuery) validate() error {
+	for _, p := range q.priorities {
+		if _, ok := findbugs.ParsePriority(p); !ok {
+			return errors.Errorf("unknown priority %s", p)
+		}
+	}
+	for _, p := range q.patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return errors.Wrapf(err, "invalid pattern %s", p)
+		}
+	}
+	return nil
+}
+
+func (q bugQuery) matches(b findbugs.BugInstance) bool {
+	return q.priorities.any(func(p string) bool {
+		priority, _ := findbugs.ParsePriority(p)
+		return priority == b.Priority
+	}) && q.categories.any(func(c string) bool {
+		return strings.EqualFold(c, b.Category)
+	}) && q.patterns.any(func(p string) bool {
+		matched, _ := path.Match(p, b.Type)
+		return matched
+	}) && q.packages.any(func(p string) bool {
+		pkg := b.Package()
+		return pkg == p || strings.HasPrefix(pkg, p+".")
+	})
+}
+
+// listFlag is a flag.Value holding a comma separated list, which may be repeated
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, splitList(value)...)
+	return nil
+}
+
+// any returns true if the list is empty, or fn returns true for one of its values
+func (l listFlag) any(fn func(string) bool) bool {
+	if len(l) == 0 {
+		return true
+	}
+	for _, v := range l {
+		if fn(v) {
+			return true
+		}
+	}
+	return false
+}
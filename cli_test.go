@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestAnalyzeUsesEnvironment(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		args      []string
+		wantBugs  float64
+		wantTag   string
+		wantError bool
+	}{
+		{
+			name:     "defaults",
+			wantBugs: 5,
+			wantTag:  "basis:priority",
+		},
+		{
+			name:     "basis",
+			env:      map[string]string{"SPOTBUGS_BASIS": "rank"},
+			wantBugs: 5,
+			wantTag:  "basis:rank",
+		},
+		{
+			name:      "quality gate",
+			env:       map[string]string{"SPOTBUGS_GATE_PRIORITY": "high"},
+			wantError: true,
+		},
+		{
+			name: "filters",
+			env: map[string]string{
+				"SPOTBUGS_GATE_PRIORITY":   "high",
+				"SPOTBUGS_EXCLUDE_FILTERS": "testdata/exclude-high.xml",
+			},
+			wantBugs: 3,
+			wantTag:  "quality-gate:passed",
+		},
+		{
+			name:     "flags override the environment",
+			env:      map[string]string{"SPOTBUGS_BASIS": "rank", "SPOTBUGS_EFFORT": "max"},
+			args:     []string{"-basis", "both", "-effort", "min"},
+			wantBugs: 5,
+			wantTag:  "basis:both",
+		},
+		{
+			name: "filter flags override the environment",
+			env: map[string]string{
+				"SPOTBUGS_GATE_PRIORITY":   "high",
+				"SPOTBUGS_EXCLUDE_FILTERS": "testdata/exclude-high.xml",
+			},
+			args:      []string{"-exclude", "testdata/exclude.xml"},
+			wantError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+			var out bytes.Buffer
+			err := runAnalyze(append([]string{"-o", "json", sampleReport}, test.args...), &out)
+			if test.wantError {
+				if err == nil {
+					t.Error("got no error, want the quality gate to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var summary struct {
+				TotalBugs float64  `json:"totalBugs"`
+				Tags      []string `json:"tags"`
+			}
+			if err := json.Unmarshal(out.Bytes(), &summary); err != nil {
+				t.Fatal(err)
+			}
+			if summary.TotalBugs != test.wantBugs {
+				t.Errorf("got %v bugs, want %v", summary.TotalBugs, test.wantBugs)
+			}
+			found := false
+			for _, tag := range summary.Tags {
+				found = found || tag == test.wantTag
+				if test.env["SPOTBUGS_EFFORT"] != "" && tag == "effort:"+test.env["SPOTBUGS_EFFORT"] {
+					t.Errorf("got tag %s, want the effort of the flag", tag)
+				}
+			}
+			if !found {
+				t.Errorf("got tags %v, want %s", summary.Tags, test.wantTag)
+			}
+		})
+	}
+}
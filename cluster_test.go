@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	jenkinsv1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	jenkinsclientv1 "github.com/jenkins-x/jx/pkg/client/clientset/versioned/typed/jenkins.io/v1"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+)
+
+// sampleReport is a SpotBugs report with 5 bugs in 3 packages, from a run with 2 missing classes
+const sampleReport = "testdata/report.xml"
+
+// fakeCluster serves PipelineActivities the way the API server does, supporting get, watch and JSON merge patches,
+// which it records
+type fakeCluster struct {
+	server *httptest.Server
+
+	mu         sync.Mutex
+	activities map[string]map[string]interface{}
+	patches    []map[string]interface{}
+	watches    int
+	// watchEvents are sent by every watch after the ADDED events of the activities
+	watchEvents []map[string]interface{}
+}
+
+const activitiesAPIPath = "/apis/jenkins.io/v1/namespaces/"
+
+func newFakeCluster(t *testing.T, acts ...*jenkinsv1.PipelineActivity) (*fakeCluster, jenkinsclientv1.JenkinsV1Interface) {
+	c := &fakeCluster{activities: make(map[string]map[string]interface{})}
+	for _, act := range acts {
+		act.APIVersion = "jenkins.io/v1"
+		act.Kind = "PipelineActivity"
+		if act.ResourceVersion == "" {
+			act.ResourceVersion = "1"
+		}
+		object, err := toJSONMap(act)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.activities[act.Namespace+"/"+act.Name] = object
+	}
+	c.server = httptest.NewServer(http.HandlerFunc(c.serve))
+	t.Cleanup(c.server.Close)
+	client, err := jenkinsclientv1.NewForConfig(&rest.Config{Host: c.server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, client
+}
+
+func (c *fakeCluster) serve(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, activitiesAPIPath), "/")
+	if len(parts) == 2 && parts[1] == "pipelineactivities" && r.URL.Query().Get("watch") == "true" {
+		c.watch(w, parts[0])
+		return
+	}
+	if len(parts) != 3 || parts[1] != "pipelineactivities" {
+		http.NotFound(w, r)
+		return
+	}
+	key := parts[0] + "/" + parts[2]
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	object, ok := c.activities[key]
+	if !ok {
+		writeStatus(w, http.StatusNotFound, "NotFound")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPatch:
+		var patch map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		metadata := object["metadata"].(map[string]interface{})
+		if patchMetadata, ok := patch["metadata"].(map[string]interface{}); ok {
+			if version, ok := patchMetadata["resourceVersion"]; ok && version != metadata["resourceVersion"] {
+				writeStatus(w, http.StatusConflict, "Conflict")
+				return
+			}
+		}
+		c.patches = append(c.patches, patch)
+		object = mergePatch(object, patch).(map[string]interface{})
+		metadata = object["metadata"].(map[string]interface{})
+		version, _ := strconv.Atoi(metadata["resourceVersion"].(string))
+		metadata["resourceVersion"] = strconv.Itoa(version + 1)
+		c.activities[key] = object
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(object)
+}
+
+// watch sends an ADDED event for every activity in namespace, followed by watchEvents, and ends the watch, as the API
+// server does once a watch times out
+func (c *fakeCluster) watch(w http.ResponseWriter, namespace string) {
+	c.mu.Lock()
+	c.watches++
+	var events []map[string]interface{}
+	for key, object := range c.activities {
+		if strings.HasPrefix(key, namespace+"/") {
+			events = append(events, map[string]interface{}{"type": "ADDED", "object": object})
+		}
+	}
+	events = append(events, c.watchEvents...)
+	c.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	for _, event := range events {
+		encoder.Encode(event)
+	}
+}
+
+func (c *fakeCluster) watchCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.watches
+}
+
+// activity returns the stored PipelineActivity namespace/name as JSON
+func (c *fakeCluster) activity(namespace, name string) map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.activities[namespace+"/"+name]
+}
+
+func (c *fakeCluster) patchCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.patches)
+}
+
+func writeStatus(w http.ResponseWriter, code int, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"kind":       "Status",
+		"apiVersion": "v1",
+		"status":     "Failure",
+		"reason":     reason,
+		"code":       code,
+	})
+}
+
+// mergePatch applies a JSON merge patch (RFC 7386) to target
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+	return targetObject
+}
+
+// fileFetcher serves reports from files by URL, counting the fetches
+type fileFetcher struct {
+	mu      sync.Mutex
+	files   map[string]string
+	fetches int
+}
+
+func (f *fileFetcher) Fetch(u *url.URL) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fetches++
+	path, ok := f.files[u.String()]
+	if !ok {
+		return nil, errors.Errorf("%s not found", u)
+	}
+	return os.Open(path)
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// watchConfig selects the PipelineActivities the analyzer processes
+type watchConfig struct {
+	// Namespaces to watch, ignored if AllNamespaces is set
+	Namespaces []string
+	// AllNamespaces watches PipelineActivities across the whole cluster
+	AllNamespaces bool
+	// LabelSelector restricts the PipelineActivities watched by label
+	LabelSelector string
+	// FieldSelector restricts the PipelineActivities watched by field
+	FieldSelector string
+	// Workers is the number of PipelineActivities processed at once
+	Workers int
+}
+
+// defaultWorkers is the number of PipelineActivities processed at once unless configured otherwise
+const defaultWorkers = 4
+
+// watchConfigFromEnv reads the watch configuration from the environment:
+//
+//	SPOTBUGS_NAMESPACES     comma separated list of namespaces to watch
+//	SPOTBUGS_NAMESPACE      a single namespace to watch, kept for backwards compatibility
+//	SPOTBUGS_ALL_NAMESPACES "true" to watch every namespace in the cluster
+//	SPOTBUGS_LABEL_SELECTOR label selector for PipelineActivities
+//	SPOTBUGS_FIELD_SELECTOR field selector for PipelineActivities
+//	SPOTBUGS_WORKERS        number of PipelineActivities processed at once
+//
+// contextNamespace, the namespace of the kubeconfig context, is watched if no namespaces are set
+func watchConfigFromEnv(contextNamespace string) (watchConfig, error) {
+	config := watchConfig{
+		Namespaces:    splitList(os.Getenv("SPOTBUGS_NAMESPACES") + "," + os.Getenv("SPOTBUGS_NAMESPACE")),
+		AllNamespaces: os.Getenv("SPOTBUGS_ALL_NAMESPACES") == "true",
+		LabelSelector: os.Getenv("SPOTBUGS_LABEL_SELECTOR"),
+		FieldSelector: os.Getenv("SPOTBUGS_FIELD_SELECTOR"),
+		Workers:       defaultWorkers,
+	}
+	if !config.AllNamespaces && len(config.Namespaces) == 0 && contextNamespace != "" {
+		config.Namespaces = []string{contextNamespace}
+	}
+	if value := os.Getenv("SPOTBUGS_WORKERS"); value != "" {
+		workers, err := strconv.Atoi(value)
+		if err != nil {
+			return config, errors.Errorf("invalid number of workers %q", value)
+		}
+		config.Workers = workers
+	}
+	return config, config.validate()
+}
+
+func (c watchConfig) validate() error {
+	if c.AllNamespaces && len(c.Namespaces) > 0 {
+		return errors.New("either watch all namespaces or a list of namespaces, not both")
+	}
+	if !c.AllNamespaces && len(c.Namespaces) == 0 {
+		return errors.New("no namespaces to watch, set SPOTBUGS_NAMESPACES, SPOTBUGS_ALL_NAMESPACES=true or the " +
+			"namespace of the kubeconfig context")
+	}
+	if _, err := labels.Parse(c.LabelSelector); err != nil {
+		return errors.Wrapf(err, "invalid label selector %q", c.LabelSelector)
+	}
+	if _, err := fields.ParseSelector(c.FieldSelector); err != nil {
+		return errors.Wrapf(err, "invalid field selector %q", c.FieldSelector)
+	}
+	if c.Workers < 1 {
+		return errors.New("at least one worker is needed to process PipelineActivities")
+	}
+	return nil
+}
+
+// watchNamespaces returns the namespaces to start a watch in, metav1.NamespaceAll for a cluster-wide watch
+func (c watchConfig) watchNamespaces() []string {
+	if c.AllNamespaces {
+		return []string{metav1.NamespaceAll}
+	}
+	return c.Namespaces
+}
+
+func (c watchConfig) listOptions() metav1.ListOptions {
+	return metav1.ListOptions{
+		LabelSelector: c.LabelSelector,
+		FieldSelector: c.FieldSelector,
+	}
+}
+
+// splitList splits a comma separated list, dropping empty entries and duplicates
+func splitList(value string) []string {
+	result := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" && !seen[item] {
+			seen[item] = true
+			result = append(result, item)
+		}
+	}
+	return result
+}
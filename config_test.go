@@ -0,0 +1,151 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var watchEnvVars = []string{"SPOTBUGS_NAMESPACES", "SPOTBUGS_NAMESPACE", "SPOTBUGS_ALL_NAMESPACES",
+	"SPOTBUGS_LABEL_SELECTOR", "SPOTBUGS_FIELD_SELECTOR", "SPOTBUGS_WORKERS"}
+
+func TestWatchConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name             string
+		env              map[string]string
+		contextNamespace string
+		want             watchConfig
+		watchNamespaces  []string
+		valid            bool
+	}{
+		{
+			name:            "namespace list",
+			env:             map[string]string{"SPOTBUGS_NAMESPACES": "jx, jx-staging,,jx"},
+			want:            watchConfig{Namespaces: []string{"jx", "jx-staging"}, Workers: defaultWorkers},
+			watchNamespaces: []string{"jx", "jx-staging"},
+			valid:           true,
+		},
+		{
+			name:            "single namespace",
+			env:             map[string]string{"SPOTBUGS_NAMESPACE": "jx"},
+			want:            watchConfig{Namespaces: []string{"jx"}, Workers: defaultWorkers},
+			watchNamespaces: []string{"jx"},
+			valid:           true,
+		},
+		{
+			name:            "namespace list and single namespace",
+			env:             map[string]string{"SPOTBUGS_NAMESPACES": "jx-staging", "SPOTBUGS_NAMESPACE": "jx"},
+			want:            watchConfig{Namespaces: []string{"jx-staging", "jx"}, Workers: defaultWorkers},
+			watchNamespaces: []string{"jx-staging", "jx"},
+			valid:           true,
+		},
+		{
+			name:            "all namespaces",
+			env:             map[string]string{"SPOTBUGS_ALL_NAMESPACES": "true"},
+			want:            watchConfig{Namespaces: []string{}, AllNamespaces: true, Workers: defaultWorkers},
+			watchNamespaces: []string{metav1.NamespaceAll},
+			valid:           true,
+		},
+		{
+			name:  "all namespaces and a namespace list",
+			env:   map[string]string{"SPOTBUGS_ALL_NAMESPACES": "true", "SPOTBUGS_NAMESPACES": "jx"},
+			valid: false,
+		},
+		{
+			name:             "context namespace",
+			contextNamespace: "jx",
+			want:             watchConfig{Namespaces: []string{"jx"}, Workers: defaultWorkers},
+			watchNamespaces:  []string{"jx"},
+			valid:            true,
+		},
+		{
+			name:             "namespace list over the context namespace",
+			env:              map[string]string{"SPOTBUGS_NAMESPACES": "jx-staging"},
+			contextNamespace: "jx",
+			want:             watchConfig{Namespaces: []string{"jx-staging"}, Workers: defaultWorkers},
+			watchNamespaces:  []string{"jx-staging"},
+			valid:            true,
+		},
+		{
+			name:             "all namespaces over the context namespace",
+			env:              map[string]string{"SPOTBUGS_ALL_NAMESPACES": "true"},
+			contextNamespace: "jx",
+			want:             watchConfig{Namespaces: []string{}, AllNamespaces: true, Workers: defaultWorkers},
+			watchNamespaces:  []string{metav1.NamespaceAll},
+			valid:            true,
+		},
+		{
+			name:  "no namespaces",
+			valid: false,
+		},
+		{
+			name:  "empty namespace list",
+			env:   map[string]string{"SPOTBUGS_NAMESPACES": " , "},
+			valid: false,
+		},
+		{
+			name: "selectors",
+			env: map[string]string{
+				"SPOTBUGS_NAMESPACE":      "jx",
+				"SPOTBUGS_LABEL_SELECTOR": "owner=example,repository in (demo,other)",
+				"SPOTBUGS_FIELD_SELECTOR": "metadata.name!=demo-1",
+			},
+			want: watchConfig{
+				Namespaces:    []string{"jx"},
+				LabelSelector: "owner=example,repository in (demo,other)",
+				FieldSelector: "metadata.name!=demo-1",
+				Workers:       defaultWorkers,
+			},
+			watchNamespaces: []string{"jx"},
+			valid:           true,
+		},
+		{
+			name:  "invalid label selector",
+			env:   map[string]string{"SPOTBUGS_NAMESPACE": "jx", "SPOTBUGS_LABEL_SELECTOR": "owner in example"},
+			valid: false,
+		},
+		{
+			name:  "invalid field selector",
+			env:   map[string]string{"SPOTBUGS_NAMESPACE": "jx", "SPOTBUGS_FIELD_SELECTOR": "metadata.name"},
+			valid: false,
+		},
+		{
+			name:            "workers",
+			env:             map[string]string{"SPOTBUGS_NAMESPACE": "jx", "SPOTBUGS_WORKERS": "8"},
+			want:            watchConfig{Namespaces: []string{"jx"}, Workers: 8},
+			watchNamespaces: []string{"jx"},
+			valid:           true,
+		},
+		{
+			name:  "workers not a number",
+			env:   map[string]string{"SPOTBUGS_NAMESPACE": "jx", "SPOTBUGS_WORKERS": "many"},
+			valid: false,
+		},
+		{
+			name:  "no workers",
+			env:   map[string]string{"SPOTBUGS_NAMESPACE": "jx", "SPOTBUGS_WORKERS": "0"},
+			valid: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, name := range watchEnvVars {
+				t.Setenv(name, test.env[name])
+			}
+			config, err := watchConfigFromEnv(test.contextNamespace)
+			if (err == nil) != test.valid {
+				t.Fatalf("got error %v, want valid %t", err, test.valid)
+			}
+			if !test.valid {
+				return
+			}
+			if !reflect.DeepEqual(config, test.want) {
+				t.Errorf("got %+v, want %+v", config, test.want)
+			}
+			if got := config.watchNamespaces(); !reflect.DeepEqual(got, test.watchNamespaces) {
+				t.Errorf("got watch namespaces %q, want %q", got, test.watchNamespaces)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	jenkinsv1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+const (
+	// costHistorySize is the number of builds of a pipeline whose analysis cost is kept
+	costHistorySize = 10
+	// costBaselineBuilds is the number of previous builds the analysis cost of a build is compared with
+	costBaselineBuilds = 5
+)
+
+// analysisCost is the cost of running SpotBugs for a build. It is recorded on the PipelineActivity, so the cost of
+// the builds of a pipeline can be compared over time
+type analysisCost struct {
+	ClockSeconds float64 `json:"clockSeconds"`
+	CPUSeconds   float64 `json:"cpuSeconds"`
+	GCSeconds    float64 `json:"gcSeconds"`
+	PeakMBytes   float64 `json:"peakMBytes"`
+}
+
+func newAnalysisCost(summary findbugs.FindBugsSummary) analysisCost {
+	return analysisCost{
+		ClockSeconds: float64(summary.ClockSeconds),
+		CPUSeconds:   float64(summary.CPUSeconds),
+		GCSeconds:    float64(summary.GCSeconds),
+		PeakMBytes:   float64(summary.PeakMBytes),
+	}
+}
+
+func (c analysisCost) annotation() string {
+	data, _ := json.Marshal(c)
+	return string(data)
+}
+
+type buildCost struct {
+	build int
+	cost  analysisCost
+}
+
+// costHistory keeps the analysis cost of the latest builds of every pipeline. It is filled from the annotations of
+// the PipelineActivities seen by the watch, so it is rebuilt whenever the analyzer starts
+type costHistory struct {
+	mu        sync.Mutex
+	pipelines map[string][]buildCost
+}
+
+func newCostHistory() *costHistory {
+	return &costHistory{pipelines: make(map[string][]buildCost)}
+}
+
+// pipelineBuild returns the pipeline and build number of act, false if act isn't a numbered build of a pipeline
+func pipelineBuild(act *jenkinsv1.PipelineActivity) (string, int, bool) {
+	build, err := strconv.Atoi(act.Spec.Build)
+	if err != nil || act.Spec.Pipeline == "" {
+		return "", 0, false
+	}
+	return act.Spec.Pipeline, build, true
+}
+
+// recordAnnotation adds the analysis cost recorded on act, if any, to the history
+func (h *costHistory) recordAnnotation(act *jenkinsv1.PipelineActivity) {
+	value, ok := act.Annotations[annotationAnalysisCost]
+	if !ok {
+		return
+	}
+	var cost analysisCost
+	if err := json.Unmarshal([]byte(value), &cost); err != nil {
+		return
+	}
+	h.record(act, cost)
+}
+
+// record adds the analysis cost of the build act to the history, replacing a cost recorded for the same build before
+func (h *costHistory) record(act *jenkinsv1.PipelineActivity, cost analysisCost) {
+	pipeline, build, ok := pipelineBuild(act)
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	builds := h.pipelines[pipeline]
+	for i := range builds {
+		if builds[i].build == build {
+			builds[i].cost = cost
+			return
+		}
+	}
+	builds = append(builds, buildCost{build: build, cost: cost})
+	sort.Slice(builds, func(i, j int) bool { return builds[i].build < builds[j].build })
+	if len(builds) > costHistorySize {
+		builds = builds[len(builds)-costHistorySize:]
+	}
+	h.pipelines[pipeline] = builds
+}
+
+// regressions compares cost with the median cost of the previous builds of the pipeline of act, describing the
+// measures which exceed it by more than factor. There are none if factor is 0 or there are no previous builds
+func (h *costHistory) regressions(act *jenkinsv1.PipelineActivity, cost analysisCost, factor float64) []string {
+	pipeline, build, ok := pipelineBuild(act)
+	if !ok || factor <= 0 {
+		return nil
+	}
+	var previous []analysisCost
+	h.mu.Lock()
+	for _, b := range h.pipelines[pipeline] {
+		if b.build < build {
+			previous = append(previous, b.cost)
+		}
+	}
+	h.mu.Unlock()
+	if len(previous) == 0 {
+		return nil
+	}
+	if len(previous) > costBaselineBuilds {
+		previous = previous[len(previous)-costBaselineBuilds:]
+	}
+	measures := []struct {
+		name  string
+		unit  string
+		value func(analysisCost) float64
+	}{
+		{"analysis time", "s", func(c analysisCost) float64 { return c.ClockSeconds }},
+		{"CPU time", "s", func(c analysisCost) float64 { return c.CPUSeconds }},
+		{"peak memory", "MB", func(c analysisCost) float64 { return c.PeakMBytes }},
+	}
+	var result []string
+	for _, m := range measures {
+		values := make([]float64, 0, len(previous))
+		for _, c := range previous {
+			values = append(values, m.value(c))
+		}
+		baseline := median(values)
+		if current := m.value(cost); baseline > 0 && current > baseline*factor {
+			result = append(result, fmt.Sprintf("%s of %.1f%s is %.1f times the median of %.1f%s of the previous %d builds",
+				m.name, current, m.unit, current/baseline, baseline, m.unit, len(previous)))
+		}
+	}
+	return result
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
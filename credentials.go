@@ -0,0 +1,123 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/jenkins-x/ext-spotbugs/fetch"
+	"github.com/jenkins-x/ext-spotbugs/kube"
+)
+
+// defaultCredentialsReload is how often the Secrets holding credentials are read again unless configured otherwise
+const defaultCredentialsReload = time.Minute
+
+// secretRef references a Secret
+type secretRef struct {
+	Namespace string
+	Name      string
+}
+
+func (r secretRef) String() string {
+	return r.Namespace + "/" + r.Name
+}
+
+// credentialsConfig references the Secrets holding the credentials of the hosts reports are fetched from
+type credentialsConfig struct {
+	// Secrets maps hosts, either a host name or host:port, to the Secrets holding their credentials
+	Secrets map[string]secretRef
+	// Reload is how often the Secrets are read again, so changed credentials are picked up
+	Reload time.Duration
+}
+
+// credentialsConfigFromEnv reads the credentials configuration from the environment:
+//
+//	SPOTBUGS_CREDENTIALS        comma separated list of host=secret, where secret is the name of a Secret in
+//	                            POD_NAMESPACE or namespace/name. See fetch.CredentialsFromSecret for its keys
+//	SPOTBUGS_CREDENTIALS_RELOAD how often the Secrets are read again, e.g. 30s
+func credentialsConfigFromEnv() (credentialsConfig, error) {
+	config := credentialsConfig{
+		Secrets: make(map[string]secretRef),
+		Reload:  defaultCredentialsReload,
+	}
+	for _, item := range splitList(os.Getenv("SPOTBUGS_CREDENTIALS")) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return config, errors.Errorf("invalid credentials %q, must be host=secret", item)
+		}
+		ref := secretRef{Namespace: os.Getenv("POD_NAMESPACE"), Name: strings.TrimSpace(parts[1])}
+		if i := strings.Index(ref.Name, "/"); i >= 0 {
+			ref.Namespace, ref.Name = ref.Name[:i], ref.Name[i+1:]
+		}
+		if ref.Namespace == "" || ref.Name == "" {
+			return config, errors.Errorf("invalid credentials %q, the Secret needs a namespace, set POD_NAMESPACE", item)
+		}
+		config.Secrets[strings.ToLower(strings.TrimSpace(parts[0]))] = ref
+	}
+	if value := os.Getenv("SPOTBUGS_CREDENTIALS_RELOAD"); value != "" {
+		reload, err := time.ParseDuration(value)
+		if err != nil || reload <= 0 {
+			return config, errors.Errorf("invalid credentials reload interval %q", value)
+		}
+		config.Reload = reload
+	}
+	return config, nil
+}
+
+// credentialsLoader keeps the credentials of a fetch.CredentialStore in sync with the Secrets they are read from
+type credentialsLoader struct {
+	secrets *kube.SecretClient
+	store   *fetch.CredentialStore
+	config  credentialsConfig
+	// versions are the resource versions of the Secrets last loaded for each host
+	versions map[string]string
+}
+
+func newCredentialsLoader(secrets *kube.SecretClient, store *fetch.CredentialStore,
+	config credentialsConfig) *credentialsLoader {
+	return &credentialsLoader{
+		secrets:  secrets,
+		store:    store,
+		config:   config,
+		versions: make(map[string]string),
+	}
+}
+
+// run loads the credentials and reloads them every config.Reload until stop is closed
+func (l *credentialsLoader) run(stop <-chan struct{}) {
+	wait.Until(l.load, l.config.Reload, stop)
+}
+
+// load reads the Secrets, replacing the credentials of the hosts whose Secret changed. If a Secret can't be read the
+// previous credentials are kept, unless it was deleted
+func (l *credentialsLoader) load() {
+	for host, ref := range l.config.Secrets {
+		secret, err := l.secrets.Get(ref.Namespace, ref.Name)
+		if err != nil {
+			if apierrors.IsNotFound(err) && l.versions[host] != "" {
+				log.Printf("Secret %s with the credentials of %s was deleted\n", ref, host)
+				l.store.Set(host, nil)
+				delete(l.versions, host)
+			} else {
+				log.Printf("Error reading Secret %s with the credentials of %s: %v\n", ref, host, err)
+			}
+			continue
+		}
+		if secret.ResourceVersion == l.versions[host] {
+			continue
+		}
+		credentials, err := fetch.CredentialsFromSecret(secret.Data)
+		if err != nil {
+			log.Printf("Error reading Secret %s with the credentials of %s: %v\n", ref, host, err)
+			continue
+		}
+		l.store.Set(host, credentials)
+		l.versions[host] = secret.ResourceVersion
+		log.Printf("Loaded the credentials of %s from Secret %s\n", host, ref)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"k8s.io/client-go/rest"
+
+	"github.com/jenkins-x/ext-spotbugs/fetch"
+	"github.com/jenkins-x/ext-spotbugs/kube"
+)
+
+// fakeSecrets serves a single Secret the way the API server does, or the status code set instead
+type fakeSecrets struct {
+	mu              sync.Mutex
+	resourceVersion string
+	token           string
+	code            int
+	gets            int
+}
+
+func (s *fakeSecrets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gets++
+	if r.URL.Path != "/api/v1/namespaces/jx/secrets/report-credentials" {
+		http.NotFound(w, r)
+		return
+	}
+	switch s.code {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		writeStatus(w, s.code, "NotFound")
+		return
+	default:
+		writeStatus(w, s.code, "InternalError")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"namespace":       "jx",
+			"name":            "report-credentials",
+			"resourceVersion": s.resourceVersion,
+		},
+		"data": map[string]string{
+			fetch.SecretKeyToken:    base64.StdEncoding.EncodeToString([]byte(s.token)),
+			fetch.SecretKeyInsecure: base64.StdEncoding.EncodeToString([]byte("true")),
+		},
+	})
+}
+
+func (s *fakeSecrets) set(code int, resourceVersion, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.code, s.resourceVersion, s.token = code, resourceVersion, token
+}
+
+func TestCredentialsLoaderReloads(t *testing.T) {
+	var mu sync.Mutex
+	var authorization string
+	reports := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		authorization = r.Header.Get("Authorization")
+		mu.Unlock()
+		w.Write([]byte(readFile(t, sampleReport)))
+	}))
+	defer reports.Close()
+	reportURL, err := url.Parse(reports.URL + "/spotbugsXml.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := &fakeSecrets{}
+	server := httptest.NewServer(secrets)
+	defer server.Close()
+	client, err := kube.NewSecretClient(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := fetch.NewCredentialStore()
+	fetcher := fetch.New(fetch.Config{Credentials: store, Policy: fetch.Policy{AllowPrivate: true}})
+	loader := newCredentialsLoader(client, store, credentialsConfig{
+		Secrets: map[string]secretRef{strings.ToLower(reportURL.Host): {Namespace: "jx", Name: "report-credentials"}},
+	})
+	sentAuthorization := func() string {
+		body, err := fetcher.Fetch(reportURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(body)
+		body.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		return authorization
+	}
+
+	steps := []struct {
+		name            string
+		code            int
+		resourceVersion string
+		token           string
+		want            string
+	}{
+		{"loaded", http.StatusOK, "1", "one", "Bearer one"},
+		// Unchanged Secrets aren't parsed again
+		{"same version", http.StatusOK, "1", "ignored", "Bearer one"},
+		{"changed", http.StatusOK, "2", "two", "Bearer two"},
+		{"unreadable", http.StatusInternalServerError, "", "", "Bearer two"},
+		{"deleted", http.StatusNotFound, "", "", ""},
+		{"created again", http.StatusOK, "5", "five", "Bearer five"},
+	}
+	for _, step := range steps {
+		secrets.set(step.code, step.resourceVersion, step.token)
+		loader.load()
+		if got := sentAuthorization(); got != step.want {
+			t.Errorf("%s: got Authorization %q, want %q", step.name, got, step.want)
+		}
+	}
+}
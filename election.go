@@ -0,0 +1,79 @@
+package main
+
+import (
+	"log"
+	"os"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+
+	"github.com/jenkins-x/ext-spotbugs/leader"
+)
+
+const defaultLeaseName = "ext-spotbugs"
+
+// leaderStatus records whether this replica currently processes events
+type leaderStatus struct {
+	leading int32
+}
+
+func (s *leaderStatus) IsLeader() bool {
+	return atomic.LoadInt32(&s.leading) == 1
+}
+
+func (s *leaderStatus) set(leading bool) {
+	value := int32(0)
+	if leading {
+		value = 1
+	}
+	atomic.StoreInt32(&s.leading, value)
+}
+
+// runLeaderElection blocks until this replica acquires the lease and then watches for activities for as long as it
+// holds it. Losing the lease is fatal, so that the pod is restarted and rejoins as a standby
+func runLeaderElection(config *rest.Config, a *analyzer, watched watchConfig, status *leaderStatus) error {
+	namespace := os.Getenv("POD_NAMESPACE")
+	if namespace == "" {
+		return errors.New("leader election requires POD_NAMESPACE to be set")
+	}
+	name := os.Getenv("SPOTBUGS_LEASE_NAME")
+	if name == "" {
+		name = defaultLeaseName
+	}
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return errors.Wrap(err, "unable to determine leader election identity")
+		}
+		identity = hostname
+	}
+	leases, err := leader.NewLeaseClient(config, namespace)
+	if err != nil {
+		return err
+	}
+	elector, err := leader.NewElector(leader.Config{
+		Leases:   leases,
+		Name:     name,
+		Identity: identity,
+		OnStartedLeading: func(stop <-chan struct{}) {
+			status.set(true)
+			// run only returns without an error once the lease is lost, which OnStoppedLeading handles. A leader
+			// which keeps renewing the lease must never stop processing events
+			err := a.run(watched, stop)
+			if err != nil {
+				log.Fatalf("Error watching PipelineActivities: %v\n", err)
+			}
+		},
+		OnStoppedLeading: func() {
+			status.set(false)
+			log.Fatalf("Lost lease %s/%s\n", namespace, name)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	elector.Run(nil)
+	return nil
+}
@@ -0,0 +1,159 @@
+package fetch
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+	// tarMagic is found at tarMagicOffset in the header of POSIX and GNU tar archives
+	tarMagic       = []byte("ustar")
+	tarMagicOffset = 257
+)
+
+// Reports fetches the report at u using fetcher and calls fn with every report it holds, see EachReport. maxSize
+// limits the size of the reports once decompressed, 0 for no limit, usually the MaxSize of the Policy of fetcher
+func Reports(fetcher ReportFetcher, u *url.URL, maxSize int64, fn func(name string, r io.Reader) error) error {
+	body, err := fetcher.Fetch(u)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return EachReport(u.String(), body, maxSize, fn)
+}
+
+// EachReport calls fn with every report in r. r is a report, a gzip compressed report or a zip or tar archive, which
+// may be gzip compressed, of reports, e.g. one for every module of a build. The format is recognised by its content,
+// so names don't matter, except that only the files in an archive ending in .xml or .xml.gz are passed to fn. Apart
+// from zip archives, which have their directory at the end and are read into memory, r is streamed. maxSize limits the
+// bytes passed to fn in total and the size of a zip archive once decompressed, 0 for no limit
+func EachReport(name string, r io.Reader, maxSize int64, fn func(name string, r io.Reader) error) error {
+	e := &expander{location: name, remaining: maxSize, limited: maxSize > 0, fn: fn}
+	return e.expand(name, r, true)
+}
+
+type expander struct {
+	location  string
+	remaining int64
+	limited   bool
+	fn        func(name string, r io.Reader) error
+}
+
+// expand passes the reports in r to fn. Archives are only expanded at the top level, an archive in an archive is
+// passed on as it is
+func (e *expander) expand(name string, r io.Reader, topLevel bool) error {
+	buffered := bufio.NewReaderSize(r, tarMagicOffset+len(tarMagic))
+	header, _ := buffered.Peek(tarMagicOffset + len(tarMagic))
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		decompressed, err := gzip.NewReader(buffered)
+		if err != nil {
+			return errors.Wrapf(err, "unable to decompress %s", name)
+		}
+		defer decompressed.Close()
+		return e.expand(strings.TrimSuffix(name, ".gz"), decompressed, topLevel)
+	case topLevel && bytes.HasPrefix(header, zipMagic):
+		return e.expandZip(name, buffered)
+	case topLevel && len(header) == tarMagicOffset+len(tarMagic) && bytes.Equal(header[tarMagicOffset:], tarMagic):
+		return e.expandTar(name, buffered)
+	}
+	return e.fn(name, e.limit(buffered))
+}
+
+func (e *expander) expandTar(name string, r io.Reader) error {
+	archive := tar.NewReader(r)
+	for {
+		entry, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "unable to read %s", name)
+		}
+		if entry.Typeflag != tar.TypeReg || !isReport(entry.Name) {
+			continue
+		}
+		if err := e.expand(name+"!"+entry.Name, archive, false); err != nil {
+			return err
+		}
+	}
+}
+
+func (e *expander) expandZip(name string, r io.Reader) error {
+	// The archive counts towards the maximum size as it is read, so a compressed archive can't expand in memory
+	// without limit before its entries are. Its entries are counted again as they are read
+	data, err := ioutil.ReadAll(e.limit(r))
+	if err != nil {
+		return err
+	}
+	if e.limited {
+		e.remaining += int64(len(data))
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return errors.Wrapf(err, "unable to read %s", name)
+	}
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() || !isReport(file.Name) {
+			continue
+		}
+		entry, err := file.Open()
+		if err != nil {
+			return errors.Wrapf(err, "unable to read %s in %s", file.Name, name)
+		}
+		err = e.expand(name+"!"+file.Name, entry, false)
+		entry.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// limit fails reading r once the reports read so far exceed the maximum size
+func (e *expander) limit(r io.Reader) io.Reader {
+	if !e.limited {
+		return r
+	}
+	return &expandedReader{Reader: r, expander: e}
+}
+
+type expandedReader struct {
+	io.Reader
+	expander *expander
+}
+
+func (r *expandedReader) Read(p []byte) (int, error) {
+	if r.expander.remaining < 0 {
+		return 0, r.exceeded()
+	}
+	n, err := r.Reader.Read(p)
+	r.expander.remaining -= int64(n)
+	if r.expander.remaining < 0 {
+		// Drop the bytes beyond the maximum size, so the report can't be parsed without seeing the error
+		return n + int(r.expander.remaining), r.exceeded()
+	}
+	return n, err
+}
+
+func (r *expandedReader) exceeded() error {
+	return &RejectedError{URL: r.expander.location, Reason: "the report exceeds the maximum size"}
+}
+
+// isReport returns true if the file at name in an archive is a report
+func isReport(name string) bool {
+	name = strings.ToLower(path.Base(name))
+	return strings.HasSuffix(name, ".xml") || strings.HasSuffix(name, ".xml.gz")
+}
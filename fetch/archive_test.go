@@ -0,0 +1,193 @@
+package fetch
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testReport = `<?xml version="1.0"?><BugCollection version="4.0.0"></BugCollection>`
+
+type archiveEntry struct {
+	name, content string
+}
+
+func gzipData(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipData(t *testing.T, entries ...archiveEntry) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		f, err := w.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarData(t *testing.T, entries ...archiveEntry) []byte {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, entry := range entries {
+		err := w.WriteHeader(&tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.content)),
+			Typeflag: tar.TypeReg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// collect returns the reports EachReport passes on as name=content
+func collect(t *testing.T, data []byte, maxSize int64) ([]string, error) {
+	var reports []string
+	err := EachReport("report", bytes.NewReader(data), maxSize, func(name string, r io.Reader) error {
+		content, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, name+"="+string(content))
+		return nil
+	})
+	sort.Strings(reports)
+	return reports, err
+}
+
+func TestEachReport(t *testing.T) {
+	nestedZip := string(zipData(t, archiveEntry{"inner.xml", testReport}))
+	tests := []struct {
+		name string
+		data []byte
+		want []string
+	}{
+		{
+			name: "plain",
+			data: []byte(testReport),
+			want: []string{"report=" + testReport},
+		},
+		{
+			name: "gzip",
+			data: gzipData(t, []byte(testReport)),
+			want: []string{"report=" + testReport},
+		},
+		{
+			name: "zip",
+			data: zipData(t,
+				archiveEntry{"core/target/spotbugsXml.xml", testReport},
+				archiveEntry{"web/target/spotbugsXml.xml.gz", string(gzipData(t, []byte(testReport)))},
+				archiveEntry{"README.md", "not a report"},
+			),
+			want: []string{
+				"report!core/target/spotbugsXml.xml=" + testReport,
+				"report!web/target/spotbugsXml.xml=" + testReport,
+			},
+		},
+		{
+			name: "tar.gz",
+			data: gzipData(t, tarData(t,
+				archiveEntry{"core/spotbugsXml.xml", testReport},
+				archiveEntry{"core/build.log", "not a report"},
+			)),
+			want: []string{"report!core/spotbugsXml.xml=" + testReport},
+		},
+		{
+			// Archives in archives aren't expanded
+			name: "nested zip",
+			data: zipData(t, archiveEntry{"reports.xml", nestedZip}),
+			want: []string{"report!reports.xml=" + nestedZip},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := collect(t, test.data, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("got reports\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestEachReportMaxSize(t *testing.T) {
+	large := strings.Repeat("x", 4096)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"plain", []byte(large)},
+		{"gzip", gzipData(t, []byte(large))},
+		{"tar entries", tarData(t, archiveEntry{"a.xml", large[:2048]}, archiveEntry{"b.xml", large[:2048]})},
+		// A compressed zip archive is limited before it is read into memory
+		{"zip.gz", gzipData(t, zipData(t, archiveEntry{"a.xml", large}))},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := collect(t, test.data, 3000)
+			if _, ok := IsRejected(err); !ok {
+				t.Fatalf("got error %v, want the report to be rejected", err)
+			}
+		})
+	}
+	// The size of a zip archive within the limit isn't counted together with its entries
+	data := zipData(t, archiveEntry{"a.xml", large[:1400]}, archiveEntry{"b.xml", large[:1400]})
+	if _, err := collect(t, data, 2900); err != nil {
+		t.Errorf("got error %v for a zip archive within the limit", err)
+	}
+}
+
+// dataFetcher serves data for every URL
+type dataFetcher []byte
+
+func (f dataFetcher) Fetch(u *url.URL) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(f)), nil
+}
+
+func TestReportsMaxSize(t *testing.T) {
+	u, _ := url.Parse("https://reports.example.com/spotbugsXml.xml.gz")
+	// Only the compressed report is smaller than the limit
+	fetcher := dataFetcher(gzipData(t, []byte(strings.Repeat("x", 4096))))
+	read := func(name string, r io.Reader) error {
+		_, err := io.Copy(ioutil.Discard, r)
+		return err
+	}
+	if err := Reports(fetcher, u, 3000, read); err == nil {
+		t.Error("got no error for a report exceeding the maximum size once decompressed")
+	} else if _, ok := IsRejected(err); !ok {
+		t.Errorf("got error %v, want the report to be rejected", err)
+	}
+	if err := Reports(fetcher, u, 0, read); err != nil {
+		t.Errorf("got error %v without a maximum size", err)
+	}
+}
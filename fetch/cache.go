@@ -0,0 +1,232 @@
+package fetch
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCacheSize is the size of the cache unless configured otherwise, the same as in the chart
+const DefaultCacheSize = 16 << 20
+
+// maxDrainSize is the most that is read from a body which is closed before its end to cache it. Parsers stop at the end
+// of the document, which is usually close to the end of the body, so larger rests are left unread and not cached
+const maxDrainSize = 64 << 10
+
+// Cache keeps the reports fetched over http(s), so they can be fetched again with a conditional request. If the
+// report hasn't changed the server answers 304 Not Modified and the report is served from the cache. The content is
+// stored by its SHA-256 digest, so a report found at several URLs is only kept once, and the least recently used
+// content is evicted once the cache exceeds its size
+type Cache struct {
+	mu      sync.Mutex
+	maxSize int64
+	size    int64
+	urls    map[string]*cachedURL
+	content map[string]*list.Element
+	// lru orders the content from the most to the least recently used
+	lru *list.List
+}
+
+// cachedURL are the validators of the response for a URL and the digest of its content
+type cachedURL struct {
+	etag         string
+	lastModified string
+	header       http.Header
+	digest       string
+}
+
+type cachedContent struct {
+	digest string
+	data   []byte
+	// urls are the URLs with this content
+	urls map[string]bool
+}
+
+// NewCache creates a Cache holding up to maxSize bytes
+func NewCache(maxSize int64) *Cache {
+	return &Cache{
+		maxSize: maxSize,
+		urls:    make(map[string]*cachedURL),
+		content: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// lookup returns what is cached for url and its content
+func (c *Cache) lookup(url string) (*cachedURL, []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.urls[url]
+	if !ok {
+		return nil, nil
+	}
+	element := c.content[entry.digest]
+	c.lru.MoveToFront(element)
+	return entry, element.Value.(*cachedContent).data
+}
+
+// store caches data as the content of url, evicting the least recently used content to make room for it
+func (c *Cache) store(url string, entry *cachedURL, data []byte) {
+	if int64(len(data)) > c.maxSize {
+		return
+	}
+	sum := sha256.Sum256(data)
+	entry.digest = hex.EncodeToString(sum[:])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(url)
+	element, ok := c.content[entry.digest]
+	if !ok {
+		element = c.lru.PushFront(&cachedContent{digest: entry.digest, data: data, urls: make(map[string]bool)})
+		c.content[entry.digest] = element
+		c.size += int64(len(data))
+	}
+	c.lru.MoveToFront(element)
+	element.Value.(*cachedContent).urls[url] = true
+	c.urls[url] = entry
+	for c.size > c.maxSize {
+		c.evict(c.lru.Back())
+	}
+}
+
+// remove forgets url, evicting its content if no other URL has it
+func (c *Cache) remove(url string) {
+	entry, ok := c.urls[url]
+	if !ok {
+		return
+	}
+	delete(c.urls, url)
+	element := c.content[entry.digest]
+	content := element.Value.(*cachedContent)
+	delete(content.urls, url)
+	if len(content.urls) == 0 {
+		c.evict(element)
+	}
+}
+
+func (c *Cache) evict(element *list.Element) {
+	content := element.Value.(*cachedContent)
+	for url := range content.urls {
+		delete(c.urls, url)
+	}
+	delete(c.content, content.digest)
+	c.lru.Remove(element)
+	c.size -= int64(len(content.data))
+}
+
+// cachingTransport makes conditional requests for the URLs in the cache and caches the responses with validators
+type cachingTransport struct {
+	cache *Cache
+	next  http.RoundTripper
+}
+
+func (t *cachingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Method != http.MethodGet {
+		return t.next.RoundTrip(request)
+	}
+	url := request.URL.String()
+	entry, data := t.cache.lookup(url)
+	if entry != nil {
+		request = cloneRequest(request)
+		if entry.etag != "" {
+			request.Header.Set("If-None-Match", entry.etag)
+		}
+		if entry.lastModified != "" {
+			request.Header.Set("If-Modified-Since", entry.lastModified)
+		}
+	}
+	response, err := t.next.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case response.StatusCode == http.StatusNotModified && entry != nil:
+		response.Body.Close()
+		return cachedResponse(request, entry, data), nil
+	case response.StatusCode != http.StatusOK || strings.Contains(response.Header.Get("Cache-Control"), "no-store"):
+		return response, nil
+	}
+	validators := &cachedURL{
+		etag:         response.Header.Get("ETag"),
+		lastModified: response.Header.Get("Last-Modified"),
+		header:       response.Header,
+	}
+	if validators.etag == "" && validators.lastModified == "" {
+		return response, nil
+	}
+	response.Body = &cachingBody{
+		ReadCloser: response.Body,
+		remaining:  t.cache.maxSize,
+		store: func(data []byte) {
+			t.cache.store(url, validators, data)
+		},
+	}
+	return response, nil
+}
+
+// cachedResponse answers request with the content in the cache
+func cachedResponse(request *http.Request, entry *cachedURL, data []byte) *http.Response {
+	header := make(http.Header, len(entry.header))
+	for name, values := range entry.header {
+		header[name] = values
+	}
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       request,
+	}
+}
+
+// cachingBody passes the body of a response on to the reader while keeping a copy, which is stored once the body has
+// been read completely. Bodies larger than the cache aren't kept
+type cachingBody struct {
+	io.ReadCloser
+	buffer    bytes.Buffer
+	remaining int64
+	store     func(data []byte)
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.remaining >= 0 {
+		b.remaining -= int64(n)
+		if b.remaining >= 0 {
+			b.buffer.Write(p[:n])
+		} else {
+			b.buffer = bytes.Buffer{}
+		}
+	}
+	if err == io.EOF && b.remaining >= 0 {
+		b.store(b.buffer.Bytes())
+		b.remaining = -1
+	}
+	return n, err
+}
+
+// Close reads the rest of the body, up to maxDrainSize, before closing it, as parsers stop at the end of the document
+// and the body can't be cached unless it has been read completely
+func (b *cachingBody) Close() error {
+	if b.remaining >= 0 {
+		drain := b.remaining + 1
+		if drain > maxDrainSize {
+			drain = maxDrainSize
+		}
+		io.CopyN(ioutil.Discard, b, drain)
+	}
+	return b.ReadCloser.Close()
+}
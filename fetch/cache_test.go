@@ -0,0 +1,199 @@
+package fetch
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewCache(10)
+	cache.store("a", &cachedURL{etag: `"a"`}, []byte("aaaa"))
+	cache.store("b", &cachedURL{etag: `"b"`}, []byte("bbbb"))
+	// Using a makes b the least recently used
+	if entry, _ := cache.lookup("a"); entry == nil {
+		t.Fatal("a isn't cached")
+	}
+	cache.store("c", &cachedURL{etag: `"c"`}, []byte("cccc"))
+	for url, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if entry, _ := cache.lookup(url); (entry != nil) != want {
+			t.Errorf("got %s cached %t, want %t", url, entry != nil, want)
+		}
+	}
+	if cache.size != 8 {
+		t.Errorf("got size %d, want 8", cache.size)
+	}
+
+	// Content larger than the cache isn't kept
+	cache.store("d", &cachedURL{etag: `"d"`}, []byte("ddddddddddd"))
+	if entry, _ := cache.lookup("d"); entry != nil {
+		t.Error("got content larger than the cache cached")
+	}
+}
+
+func TestCacheStoresContentOnce(t *testing.T) {
+	cache := NewCache(10)
+	cache.store("a", &cachedURL{etag: `"1"`}, []byte("same"))
+	cache.store("b", &cachedURL{etag: `"2"`}, []byte("same"))
+	if cache.size != 4 || len(cache.content) != 1 {
+		t.Errorf("got size %d for %d contents, want the content to be kept once", cache.size, len(cache.content))
+	}
+	// Replacing the content of a keeps the content of b
+	cache.store("a", &cachedURL{etag: `"3"`}, []byte("new"))
+	if _, data := cache.lookup("b"); string(data) != "same" {
+		t.Errorf("got %q for b, want its content to be kept", data)
+	}
+	if cache.size != 7 {
+		t.Errorf("got size %d, want 7", cache.size)
+	}
+	// Evicting content forgets every URL with it
+	cache.store("c", &cachedURL{etag: `"4"`}, []byte("new"))
+	cache.lookup("b")
+	cache.store("d", &cachedURL{etag: `"5"`}, []byte("dddddd"))
+	for url, want := range map[string]bool{"a": false, "b": true, "c": false, "d": true} {
+		if entry, _ := cache.lookup(url); (entry != nil) != want {
+			t.Errorf("got %s cached %t, want %t", url, entry != nil, want)
+		}
+	}
+}
+
+// reportServer serves a report with validators, answering conditional requests with 304 Not Modified
+type reportServer struct {
+	mu           sync.Mutex
+	content      string
+	etag         string
+	lastModified string
+	cacheControl string
+	requests     int
+	notModified  int
+}
+
+func (s *reportServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if s.etag != "" {
+		w.Header().Set("ETag", s.etag)
+	}
+	if s.lastModified != "" {
+		w.Header().Set("Last-Modified", s.lastModified)
+	}
+	if s.cacheControl != "" {
+		w.Header().Set("Cache-Control", s.cacheControl)
+	}
+	if s.etag != "" && r.Header.Get("If-None-Match") == s.etag ||
+		s.etag == "" && s.lastModified != "" && r.Header.Get("If-Modified-Since") == s.lastModified {
+		s.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write([]byte(s.content))
+}
+
+func (s *reportServer) counts() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests, s.notModified
+}
+
+func fetchThrough(t *testing.T, transport http.RoundTripper, url string) string {
+	response, err := (&http.Client{Transport: transport}).Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("got status %s, want 200 OK", response.Status)
+	}
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCachingTransport(t *testing.T) {
+	tests := []struct {
+		name            string
+		server          *reportServer
+		wantNotModified int
+	}{
+		{"etag", &reportServer{etag: `"v1"`}, 2},
+		{"last modified", &reportServer{lastModified: "Mon, 19 Oct 2026 08:00:00 GMT"}, 2},
+		{"no validators", &reportServer{}, 0},
+		{"no-store", &reportServer{etag: `"v1"`, cacheControl: "private, no-store"}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.server.content = testReport
+			server := httptest.NewServer(test.server)
+			defer server.Close()
+			transport := &cachingTransport{cache: NewCache(1 << 20), next: http.DefaultTransport}
+			for i := 0; i < 3; i++ {
+				if got := fetchThrough(t, transport, server.URL+"/report.xml"); got != testReport {
+					t.Fatalf("got %q, want the report", got)
+				}
+			}
+			requests, notModified := test.server.counts()
+			if requests != 3 || notModified != test.wantNotModified {
+				t.Errorf("got %d requests of which %d weren't modified, want 3 and %d", requests, notModified,
+					test.wantNotModified)
+			}
+		})
+	}
+}
+
+func TestCachingTransportChangedReport(t *testing.T) {
+	report := &reportServer{content: "first", etag: `"v1"`}
+	server := httptest.NewServer(report)
+	defer server.Close()
+	transport := &cachingTransport{cache: NewCache(1 << 20), next: http.DefaultTransport}
+	if got := fetchThrough(t, transport, server.URL); got != "first" {
+		t.Fatalf("got %q", got)
+	}
+	report.mu.Lock()
+	report.content, report.etag = "second", `"v2"`
+	report.mu.Unlock()
+	if got := fetchThrough(t, transport, server.URL); got != "second" {
+		t.Errorf("got %q, want the changed report", got)
+	}
+	if got := fetchThrough(t, transport, server.URL); got != "second" {
+		t.Errorf("got %q from the cache, want the changed report", got)
+	}
+}
+
+func TestCachingTransportPartialRead(t *testing.T) {
+	tests := []struct {
+		name            string
+		size            int
+		wantNotModified int
+	}{
+		// A parser stopping before the end of the body still gets the report cached
+		{"rest drained", maxDrainSize / 2, 1},
+		// A large rest isn't read just to cache the report
+		{"rest too large to drain", 2 * maxDrainSize, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := &reportServer{content: strings.Repeat("x", test.size), etag: `"v1"`}
+			server := httptest.NewServer(report)
+			defer server.Close()
+			transport := &cachingTransport{cache: NewCache(1 << 20), next: http.DefaultTransport}
+			response, err := (&http.Client{Transport: transport}).Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Read(make([]byte, 10))
+			response.Body.Close()
+			if got := fetchThrough(t, transport, server.URL); got != report.content {
+				t.Errorf("got %d bytes, want the report", len(got))
+			}
+			if _, notModified := report.counts(); notModified != test.wantNotModified {
+				t.Errorf("got %d responses which weren't modified, want %d", notModified, test.wantNotModified)
+			}
+		})
+	}
+}
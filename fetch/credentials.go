@@ -0,0 +1,208 @@
+package fetch
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The keys of a Secret holding Credentials. They follow the conventions of the kubernetes.io/basic-auth and
+// kubernetes.io/tls Secret types, SecretKeyInsecure set to "true" sends the token or password over http as well
+const (
+	SecretKeyToken    = "token"
+	SecretKeyUsername = "username"
+	SecretKeyPassword = "password"
+	SecretKeyCert     = "tls.crt"
+	SecretKeyKey      = "tls.key"
+	SecretKeyCA       = "ca.crt"
+	SecretKeyInsecure = "insecure"
+)
+
+// Credentials authenticate requests to a host
+type Credentials struct {
+	// BearerToken is sent as Authorization: Bearer
+	BearerToken string
+	// Username and Password are sent as basic authentication if there is no BearerToken
+	Username string
+	Password string
+	// Insecure sends BearerToken or Username and Password with requests over http, which otherwise only get them over
+	// https so they can't be read on the way
+	Insecure bool
+	// Certificate is presented to the host if it requests a client certificate
+	Certificate *tls.Certificate
+	// RootCAs verify the certificate of the host instead of the system CAs
+	RootCAs *x509.CertPool
+}
+
+// CredentialsFromSecret reads Credentials from the data of a Secret, see the SecretKey constants
+func CredentialsFromSecret(data map[string][]byte) (*Credentials, error) {
+	credentials := &Credentials{
+		BearerToken: strings.TrimSpace(string(data[SecretKeyToken])),
+		Username:    string(data[SecretKeyUsername]),
+		Password:    string(data[SecretKeyPassword]),
+		Insecure:    strings.TrimSpace(string(data[SecretKeyInsecure])) == "true",
+	}
+	cert, key := data[SecretKeyCert], data[SecretKeyKey]
+	if len(cert) > 0 || len(key) > 0 {
+		certificate, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid client certificate")
+		}
+		credentials.Certificate = &certificate
+	}
+	if ca := data[SecretKeyCA]; len(ca) > 0 {
+		credentials.RootCAs = x509.NewCertPool()
+		if !credentials.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.New("invalid CA bundle")
+		}
+	}
+	return credentials, nil
+}
+
+// CredentialStore holds the Credentials of hosts. They can be replaced at any time, requests which are already
+// running keep using the previous Credentials
+type CredentialStore struct {
+	mu    sync.RWMutex
+	hosts map[string]*Credentials
+}
+
+// NewCredentialStore creates an empty CredentialStore
+func NewCredentialStore() *CredentialStore {
+	return &CredentialStore{hosts: make(map[string]*Credentials)}
+}
+
+// Set replaces the Credentials of host, which is a host name or host:port. nil removes them
+func (s *CredentialStore) Set(host string, credentials *Credentials) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if credentials == nil {
+		delete(s.hosts, strings.ToLower(host))
+		return
+	}
+	s.hosts[strings.ToLower(host)] = credentials
+}
+
+// Authenticates returns true if requests to the host of u are sent with Credentials
+func (s *CredentialStore) Authenticates(u *url.URL) bool {
+	_, c := s.lookup(u)
+	return c != nil
+}
+
+// lookup returns the Credentials of the host of u and the host they are registered for, matching host:port before
+// the host name
+func (s *CredentialStore) lookup(u *url.URL) (string, *Credentials) {
+	if s == nil {
+		return "", nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	host := strings.ToLower(u.Host)
+	if c, ok := s.hosts[host]; ok {
+		return host, c
+	}
+	host = strings.ToLower(u.Hostname())
+	return host, s.hosts[host]
+}
+
+// credentialsTransport authenticates requests with the Credentials of their host. Hosts with TLS settings get a
+// transport of their own, which is replaced when their Credentials are
+type credentialsTransport struct {
+	store *CredentialStore
+	dial  func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	defaultTransport *http.Transport
+	mu               sync.Mutex
+	transports       map[string]*tlsTransport
+}
+
+type tlsTransport struct {
+	credentials *Credentials
+	transport   *http.Transport
+}
+
+func newCredentialsTransport(store *CredentialStore,
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)) *credentialsTransport {
+	return &credentialsTransport{
+		store:            store,
+		dial:             dial,
+		defaultTransport: newTransport(nil, dial),
+		transports:       make(map[string]*tlsTransport),
+	}
+}
+
+func (t *credentialsTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	host, c := t.store.lookup(request.URL)
+	if c == nil {
+		return t.defaultTransport.RoundTrip(request)
+	}
+	// Requests which already carry authorization, e.g. signed S3 requests, are sent as they are. Redirects are sent
+	// separately, so one to http doesn't get the credentials either
+	if request.Header.Get("Authorization") == "" && (request.URL.Scheme == "https" || c.Insecure) {
+		switch {
+		case c.BearerToken != "":
+			request = cloneRequest(request)
+			request.Header.Set("Authorization", "Bearer "+c.BearerToken)
+		case c.Username != "":
+			request = cloneRequest(request)
+			request.SetBasicAuth(c.Username, c.Password)
+		}
+	}
+	return t.transport(host, c).RoundTrip(request)
+}
+
+// transport returns the transport for requests to host with credentials
+func (t *credentialsTransport) transport(host string, credentials *Credentials) *http.Transport {
+	if credentials.Certificate == nil && credentials.RootCAs == nil {
+		return t.defaultTransport
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	current, ok := t.transports[host]
+	if ok && current.credentials == credentials {
+		return current.transport
+	}
+	if ok {
+		current.transport.CloseIdleConnections()
+	}
+	tlsConfig := &tls.Config{RootCAs: credentials.RootCAs}
+	if credentials.Certificate != nil {
+		tlsConfig.Certificates = []tls.Certificate{*credentials.Certificate}
+	}
+	transport := newTransport(tlsConfig, t.dial)
+	t.transports[host] = &tlsTransport{credentials: credentials, transport: transport}
+	return transport
+}
+
+// cloneRequest copies request and its headers, as a http.RoundTripper mustn't modify the request it is passed
+func cloneRequest(request *http.Request) *http.Request {
+	clone := new(http.Request)
+	*clone = *request
+	clone.Header = make(http.Header, len(request.Header))
+	for name, values := range request.Header {
+		clone.Header[name] = append([]string(nil), values...)
+	}
+	return clone
+}
+
+// newTransport creates a transport with the settings of http.DefaultTransport, connecting using dial and tlsConfig.
+// Proxies configured in the environment aren't used, as dial would only check the address of the proxy rather than
+// that of the host a report is fetched from
+func newTransport(tlsConfig *tls.Config,
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)) *http.Transport {
+	return &http.Transport{
+		DialContext:           dial,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       tlsConfig,
+	}
+}
@@ -0,0 +1,119 @@
+package fetch
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// recordingTransport records the requests it is passed instead of sending them
+type recordingTransport struct {
+	requests []*http.Request
+}
+
+func (t *recordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, request)
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: request}, nil
+}
+
+func TestCredentialsFromSecret(t *testing.T) {
+	credentials, err := CredentialsFromSecret(map[string][]byte{
+		SecretKeyToken:    []byte("secret\n"),
+		SecretKeyInsecure: []byte("true"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials.BearerToken != "secret" || !credentials.Insecure {
+		t.Errorf("got %+v, want the trimmed token and insecure", credentials)
+	}
+	if _, err := CredentialsFromSecret(map[string][]byte{SecretKeyCert: []byte("invalid")}); err == nil {
+		t.Error("got no error for an invalid client certificate")
+	}
+	if _, err := CredentialsFromSecret(map[string][]byte{SecretKeyCA: []byte("invalid")}); err == nil {
+		t.Error("got no error for an invalid CA bundle")
+	}
+}
+
+func TestCredentialsTransport(t *testing.T) {
+	store := NewCredentialStore()
+	store.Set("reports.example.com", &Credentials{BearerToken: "token"})
+	store.Set("Reports.Example.com:8443", &Credentials{Username: "user", Password: "password"})
+	store.Set("legacy.example.com", &Credentials{BearerToken: "legacy", Insecure: true})
+	tests := []struct {
+		url           string
+		authorization string
+		want          string
+	}{
+		{url: "https://reports.example.com/a.xml", want: "Bearer token"},
+		{url: "https://REPORTS.example.com:443/a.xml", want: "Bearer token"},
+		{url: "https://reports.example.com:8443/a.xml", want: "Basic dXNlcjpwYXNzd29yZA=="},
+		// Credentials aren't sent in the clear
+		{url: "http://reports.example.com/a.xml", want: ""},
+		{url: "http://legacy.example.com/a.xml", want: "Bearer legacy"},
+		{url: "https://other.example.com/a.xml", want: ""},
+		// Signed requests are sent as they are
+		{url: "https://reports.example.com/a.xml", authorization: "AWS4-HMAC-SHA256 ...", want: "AWS4-HMAC-SHA256 ..."},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			recorder := &recordingTransport{}
+			transport := newCredentialsTransport(store, nil)
+			transport.defaultTransport.RegisterProtocol("http", recorder)
+			transport.defaultTransport.RegisterProtocol("https", recorder)
+			request, err := http.NewRequest(http.MethodGet, test.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.authorization != "" {
+				request.Header.Set("Authorization", test.authorization)
+			}
+			if _, err := transport.RoundTrip(request); err != nil {
+				t.Fatal(err)
+			}
+			if got := recorder.requests[0].Header.Get("Authorization"); got != test.want {
+				t.Errorf("got Authorization %q, want %q", got, test.want)
+			}
+			if request.Header.Get("Authorization") != test.authorization {
+				t.Error("the request passed to the transport was modified")
+			}
+		})
+	}
+}
+
+func TestAuthenticated(t *testing.T) {
+	store := NewCredentialStore()
+	store.Set("reports.example.com", &Credentials{BearerToken: "token"})
+	store.Set("raw.githubusercontent.com", &Credentials{BearerToken: "token"})
+	store.Set("files.example.com", &Credentials{BearerToken: "token"})
+	config := Config{
+		Credentials: store,
+		S3:          S3Config{AccessKeyID: "AKIDEXAMPLE"},
+		GitRawURLs:  map[string]string{"git.example.com": "https://files.example.com/{repository}/{ref}/{path}"},
+	}
+	tests := []struct {
+		config Config
+		url    string
+		want   bool
+	}{
+		{config, "https://reports.example.com/a.xml", true},
+		{config, "https://public.example.com/a.xml", false},
+		{config, "s3://bucket/a.xml", true},
+		{Config{}, "s3://bucket/a.xml", false},
+		{Config{}, "https://reports.example.com/a.xml", false},
+		{config, "git+https://github.com/example/demo.git#gh-pages:a.xml", true},
+		{config, "git+https://gitlab.com/example/demo.git#master:a.xml", false},
+		{config, "git+https://git.example.com/example/demo.git#master:a.xml", true},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			u, err := url.Parse(test.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := test.config.Authenticated(u); got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package fetch
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// DefaultTimeout is how long fetching a report may take unless configured otherwise
+const DefaultTimeout = 10 * time.Second
+
+// ReportFetcher retrieves the report at a URL. The caller must close the returned reader
+type ReportFetcher interface {
+	Fetch(u *url.URL) (io.ReadCloser, error)
+}
+
+// Schemes is a ReportFetcher which delegates to the ReportFetcher registered for the scheme of the URL
+type Schemes map[string]ReportFetcher
+
+// Fetch retrieves the report at u using the ReportFetcher for its scheme
+func (s Schemes) Fetch(u *url.URL) (io.ReadCloser, error) {
+	fetcher, ok := s[strings.ToLower(u.Scheme)]
+	if !ok {
+		return nil, errors.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	return fetcher.Fetch(u)
+}
+
+// Config configures the fetchers created by New
+type Config struct {
+	// Timeout limits how long fetching a report over the network may take
+	Timeout time.Duration
+	// FileRoot is the directory file:// URLs must point into. file:// URLs aren't supported if it is empty
+	FileRoot string
+	// S3 configures fetching s3:// URLs
+	S3 S3Config
+	// Credentials authenticate the requests to hosts, which are anonymous if it is nil
+	Credentials *CredentialStore
+	// Policy restricts where reports are fetched from over the network and their size
+	Policy Policy
+	// Cache keeps the reports fetched over http(s), so unchanged reports aren't downloaded again. nil disables caching
+	Cache *Cache
+	// RateLimit limits the requests sent to every host
+	RateLimit RateLimit
+	// GitRawURLs are the templates of the raw file URLs of git servers by host, see RawGitURL
+	GitRawURLs map[string]string
+}
+
+// ConfigFromEnv reads the fetch configuration from the environment:
+//
+//	SPOTBUGS_FETCH_TIMEOUT  how long fetching a report may take, e.g. 30s
+//	SPOTBUGS_FILE_ROOT      directory, e.g. a shared volume, file:// URLs may point into
+//	SPOTBUGS_S3_ENDPOINT    URL of an S3-compatible store such as MinIO, defaults to AWS
+//	SPOTBUGS_S3_REGION      region of the buckets, defaults to us-east-1
+//	AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+//	                        credentials for s3:// URLs, anonymous if not set
+//	SPOTBUGS_FETCH_ALLOW    comma separated list of the hosts (*.example.com for subdomains), addresses and networks
+//	                        (CIDR) reports may be fetched from, any public address if not set
+//	SPOTBUGS_FETCH_ALLOW_PRIVATE
+//	                        "true" to allow fetching reports from loopback, private and link-local addresses
+//	SPOTBUGS_FETCH_MAX_SIZE the maximum size of a report, e.g. 64Mi
+//	SPOTBUGS_CACHE_SIZE     the size of the cache of fetched reports, e.g. 256Mi, 0 disables it
+//	SPOTBUGS_FETCH_RATE     the number of requests per second sent to a host, 0 for no limit
+//	SPOTBUGS_FETCH_BURST    the number of requests which may be sent to a host at once, before the rate applies
+//	SPOTBUGS_GIT_RAW_URLS   comma separated list of host=template, the URLs git servers serve raw files at, see
+//	                        RawGitURL
+func ConfigFromEnv() (Config, error) {
+	policy, err := PolicyFromEnv()
+	if err != nil {
+		return Config{}, err
+	}
+	gitRawURLs, err := RawGitURLsFromEnv()
+	if err != nil {
+		return Config{}, err
+	}
+	config := Config{
+		Timeout:    DefaultTimeout,
+		FileRoot:   os.Getenv("SPOTBUGS_FILE_ROOT"),
+		S3:         S3ConfigFromEnv(),
+		Policy:     policy,
+		GitRawURLs: gitRawURLs,
+		RateLimit: RateLimit{
+			PerSecond: DefaultRate,
+			Burst:     DefaultBurst,
+		},
+	}
+	if value := os.Getenv("SPOTBUGS_FETCH_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return config, errors.Errorf("invalid fetch timeout %q", value)
+		}
+		config.Timeout = timeout
+	}
+	cacheSize := int64(DefaultCacheSize)
+	if value := os.Getenv("SPOTBUGS_CACHE_SIZE"); value != "" {
+		size, err := resource.ParseQuantity(value)
+		if err != nil || size.Value() < 0 {
+			return config, errors.Errorf("invalid cache size %q", value)
+		}
+		cacheSize = size.Value()
+	}
+	if cacheSize > 0 {
+		config.Cache = NewCache(cacheSize)
+	}
+	if value := os.Getenv("SPOTBUGS_FETCH_RATE"); value != "" {
+		perSecond, err := strconv.ParseFloat(value, 64)
+		if err != nil || perSecond < 0 {
+			return config, errors.Errorf("invalid fetch rate %q", value)
+		}
+		config.RateLimit.PerSecond = perSecond
+	}
+	if value := os.Getenv("SPOTBUGS_FETCH_BURST"); value != "" {
+		burst, err := strconv.Atoi(value)
+		if err != nil || burst < 1 {
+			return config, errors.Errorf("invalid fetch burst %q", value)
+		}
+		config.RateLimit.Burst = burst
+	}
+	return config, nil
+}
+
+// New creates a ReportFetcher for http(s)://, file://, s3:// and git+http(s):// URLs
+func New(config Config) ReportFetcher {
+	policy := config.Policy
+	if endpoint, err := url.Parse(config.S3.Endpoint); err == nil && endpoint.Host != "" {
+		policy = policy.trust(endpoint.Hostname())
+	}
+	var transport http.RoundTripper = newCredentialsTransport(config.Credentials, policy.dialContext)
+	if config.RateLimit.PerSecond > 0 {
+		transport = newRateLimitTransport(config.RateLimit, transport)
+	}
+	if config.Cache != nil {
+		transport = &cachingTransport{cache: config.Cache, next: transport}
+	}
+	client := &http.Client{
+		Timeout:       config.Timeout,
+		Transport:     transport,
+		CheckRedirect: policy.checkRedirect,
+	}
+	httpFetcher := &HTTPFetcher{Client: client, Policy: policy}
+	gitFetcher := &GitFetcher{HTTP: httpFetcher, RawURLs: config.GitRawURLs}
+	schemes := Schemes{
+		"http":      httpFetcher,
+		"https":     httpFetcher,
+		"s3":        &S3Fetcher{Config: config.S3, Client: client},
+		"git+http":  gitFetcher,
+		"git+https": gitFetcher,
+	}
+	if config.FileRoot != "" {
+		schemes["file"] = &FileFetcher{Root: config.FileRoot}
+	}
+	return &limitedFetcher{fetcher: schemes, policy: policy}
+}
+
+// Authenticated returns true if the report at u is fetched with credentials, so it may not be public
+func (c Config) Authenticated(u *url.URL) bool {
+	switch strings.ToLower(u.Scheme) {
+	case "s3":
+		return c.S3.AccessKeyID != ""
+	case "git+http", "git+https":
+		rawURL, err := RawGitURL(u, c.GitRawURLs)
+		if err != nil {
+			return false
+		}
+		u = rawURL
+	}
+	return c.Credentials.Authenticates(u)
+}
+
+// limitedFetcher fails reading reports larger than the maximum size of the policy
+type limitedFetcher struct {
+	fetcher ReportFetcher
+	policy  Policy
+}
+
+func (f *limitedFetcher) Fetch(u *url.URL) (io.ReadCloser, error) {
+	body, err := f.fetcher.Fetch(u)
+	if err != nil {
+		return nil, err
+	}
+	return f.policy.limit(u.String(), body), nil
+}
+
+// HTTPFetcher retrieves reports over http(s). Responses with a gzip Content-Encoding are decompressed by the transport
+type HTTPFetcher struct {
+	Client *http.Client
+	// Policy rejects URLs which are never allowed. The addresses are checked by the transport of Client
+	Policy Policy
+}
+
+// Fetch retrieves the report at u, failing unless the response is successful
+func (f *HTTPFetcher) Fetch(u *url.URL) (io.ReadCloser, error) {
+	if err := f.Policy.checkURL(u); err != nil {
+		return nil, err
+	}
+	response, err := f.Client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	return checkResponse(response)
+}
+
+// checkResponse returns the body of response, or an error and closes it if it isn't successful
+func checkResponse(response *http.Response) (io.ReadCloser, error) {
+	if response.StatusCode > 299 || response.StatusCode < 200 {
+		response.Body.Close()
+		return nil, errors.Errorf("unexpected status %s", response.Status)
+	}
+	return response.Body, nil
+}
@@ -0,0 +1,46 @@
+package fetch
+
+import (
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FileFetcher retrieves reports from the file system, e.g. a volume shared with the pipelines. Only files below Root
+// can be fetched
+type FileFetcher struct {
+	Root string
+}
+
+// Fetch opens the file at file://<path>
+func (f *FileFetcher) Fetch(u *url.URL) (io.ReadCloser, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, errors.Errorf("file URL %s refers to another host", u)
+	}
+	root, err := filepath.Abs(f.Root)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Clean(filepath.FromSlash(u.Path))
+	if path != root && !strings.HasPrefix(path, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator)) {
+		return nil, errors.Errorf("file %s is outside of %s", path, root)
+	}
+	// Resolve symbolic links, so they can't point outside of the root either
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+	if resolved != resolvedRoot &&
+		!strings.HasPrefix(resolved, strings.TrimSuffix(resolvedRoot, string(filepath.Separator))+string(filepath.Separator)) {
+		return nil, errors.Errorf("file %s is outside of %s", path, root)
+	}
+	return os.Open(resolved)
+}
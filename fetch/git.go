@@ -0,0 +1,111 @@
+package fetch
+
+import (
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// GitFetcher retrieves reports committed to a git repository, such as the gh-pages branch `jx step collect` publishes
+// to. The URLs are git+https://<host>/<repository>.git#<ref>:<path>, the ref defaulting to HEAD if there is no
+// colon. The image has no git binary, so files are fetched over http(s) from the raw file endpoint of the git server,
+// which is only guessed from the host unless RawURLs has a template for it: GitHub, GitLab (any host containing
+// "gitlab") and servers serving raw files from /<repository>/raw/<ref>/<path> such as GitHub Enterprise work out of
+// the box, while e.g. Bitbucket and git servers hosted under a path need a template
+type GitFetcher struct {
+	HTTP ReportFetcher
+	// RawURLs are the templates of the raw file URLs by host, see RawGitURL
+	RawURLs map[string]string
+}
+
+// Fetch retrieves the file from the raw file endpoint of the git server
+func (f *GitFetcher) Fetch(u *url.URL) (io.ReadCloser, error) {
+	rawURL, err := RawGitURL(u, f.RawURLs)
+	if err != nil {
+		return nil, err
+	}
+	return f.HTTP.Fetch(rawURL)
+}
+
+// RawGitURLsFromEnv reads the templates of raw file URLs, see ConfigFromEnv
+func RawGitURLsFromEnv() (map[string]string, error) {
+	templates := make(map[string]string)
+	for _, item := range strings.Split(os.Getenv("SPOTBUGS_GIT_RAW_URLS"), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		host := strings.ToLower(strings.TrimSpace(parts[0]))
+		if len(parts) != 2 || host == "" {
+			return templates, errors.Errorf("invalid git raw URL %q, must be host=template", item)
+		}
+		template := strings.TrimSpace(parts[1])
+		raw, err := url.Parse(strings.NewReplacer(rawURLPlaceholders("x", "x", "x", "x", "x")...).Replace(template))
+		if err != nil || (raw.Scheme != "http" && raw.Scheme != "https") || raw.Host == "" ||
+			!strings.Contains(template, "{path}") {
+			return templates, errors.Errorf("invalid git raw URL %q, must be an http(s) URL with {path}", item)
+		}
+		templates[host] = template
+	}
+	return templates, nil
+}
+
+// RawGitURL maps a git+http(s) URL to the URL the git server serves the raw file at. If templates has a template for
+// the host of u, its placeholders {repository}, {owner}, {name}, {ref} and {path} are replaced with the escaped
+// repository path, its parent and last element, the ref and the path of the file, e.g.
+// https://bitbucket.example.com/projects/{owner}/repos/{name}/raw/{path}?at={ref}. Otherwise GitHub serves raw files
+// from raw.githubusercontent.com, GitLab from /-/raw/ and other servers, e.g. GitHub Enterprise and Gitea, from /raw/
+func RawGitURL(u *url.URL, templates map[string]string) (*url.URL, error) {
+	scheme := strings.TrimPrefix(strings.ToLower(u.Scheme), "git+")
+	if scheme != "http" && scheme != "https" {
+		return nil, errors.Errorf("unsupported git URL %s, must be git+http(s)", u)
+	}
+	ref, file := "HEAD", u.Fragment
+	if i := strings.Index(u.Fragment, ":"); i >= 0 {
+		ref, file = u.Fragment[:i], u.Fragment[i+1:]
+	}
+	file = strings.TrimPrefix(file, "/")
+	repository := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if file == "" || ref == "" || repository == "" {
+		return nil, errors.Errorf("git URL %s must be git+%s://<host>/<repository>.git#<ref>:<path>", u, scheme)
+	}
+	if template, ok := templates[strings.ToLower(u.Hostname())]; ok {
+		owner, name := path.Split(repository)
+		replacer := strings.NewReplacer(rawURLPlaceholders(escapePath(repository),
+			escapePath(strings.TrimSuffix(owner, "/")), url.PathEscape(name), escapePath(ref), escapePath(file))...)
+		raw, err := url.Parse(replacer.Replace(template))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid raw URL for git URL %s", u)
+		}
+		return raw, nil
+	}
+	raw := &url.URL{Scheme: scheme, Host: u.Host}
+	switch {
+	case strings.EqualFold(u.Hostname(), "github.com"):
+		raw.Host = "raw.githubusercontent.com"
+		raw.Path = "/" + path.Join(repository, ref, file)
+	case strings.Contains(strings.ToLower(u.Hostname()), "gitlab"):
+		raw.Path = "/" + path.Join(repository, "-", "raw", ref, file)
+	default:
+		raw.Path = "/" + path.Join(repository, "raw", ref, file)
+	}
+	return raw, nil
+}
+
+func rawURLPlaceholders(repository, owner, name, ref, file string) []string {
+	return []string{"{repository}", repository, "{owner}", owner, "{name}", name, "{ref}", ref, "{path}", file}
+}
+
+// escapePath escapes every segment of p, keeping the slashes between them
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,97 @@
+package fetch
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestRawGitURL(t *testing.T) {
+	templates := map[string]string{
+		"bitbucket.example.com": "https://bitbucket.example.com/projects/{owner}/repos/{name}/raw/{path}?at={ref}",
+		"git.example.com":       "https://git.example.com/gitea/{repository}/raw/branch/{ref}/{path}",
+	}
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"git+https://github.com/example/demo.git#gh-pages:reports/spotbugsXml.xml",
+			"https://raw.githubusercontent.com/example/demo/gh-pages/reports/spotbugsXml.xml"},
+		{"git+https://github.com/example/demo.git#reports/spotbugsXml.xml",
+			"https://raw.githubusercontent.com/example/demo/HEAD/reports/spotbugsXml.xml"},
+		{"git+https://gitlab.example.com/group/sub/demo.git#master:spotbugsXml.xml",
+			"https://gitlab.example.com/group/sub/demo/-/raw/master/spotbugsXml.xml"},
+		{"git+http://ghe.example.com:8080/example/demo.git#master:/spotbugsXml.xml",
+			"http://ghe.example.com:8080/example/demo/raw/master/spotbugsXml.xml"},
+		{"git+https://bitbucket.example.com/PROJ/demo.git#feature/x:reports/a b.xml",
+			"https://bitbucket.example.com/projects/PROJ/repos/demo/raw/reports/a%20b.xml?at=feature/x"},
+		{"git+https://GIT.example.com/example/demo.git#main:spotbugsXml.xml",
+			"https://git.example.com/gitea/example/demo/raw/branch/main/spotbugsXml.xml"},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			u, err := url.Parse(test.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := RawGitURL(u, templates)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+	for _, invalid := range []string{
+		"git://github.com/example/demo.git#master:a.xml",
+		"git+https://github.com/example/demo.git",
+		"git+https://github.com/example/demo.git#master:",
+		"git+https://github.com/#master:a.xml",
+	} {
+		u, err := url.Parse(invalid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := RawGitURL(u, templates); err == nil {
+			t.Errorf("got no error for %s", invalid)
+		}
+	}
+}
+
+func TestRawGitURLsFromEnv(t *testing.T) {
+	tests := []struct {
+		value string
+		want  map[string]string
+	}{
+		{"", map[string]string{}},
+		{" Git.example.com = https://git.example.com/{repository}/raw/{ref}/{path} ,",
+			map[string]string{"git.example.com": "https://git.example.com/{repository}/raw/{ref}/{path}"}},
+		{"git.example.com", nil},
+		{"=https://git.example.com/{path}", nil},
+		{"git.example.com=ftp://git.example.com/{path}", nil},
+		{"git.example.com=https://git.example.com/{repository}", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			t.Setenv("SPOTBUGS_GIT_RAW_URLS", test.value)
+			got, err := RawGitURLsFromEnv()
+			if test.want == nil {
+				if err == nil {
+					t.Errorf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+			for host, template := range test.want {
+				if got[host] != template {
+					t.Errorf("got template %q for %s, want %q", got[host], host, template)
+				}
+			}
+		})
+	}
+}
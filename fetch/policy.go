@@ -0,0 +1,285 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// DefaultMaxSize is the maximum size of a report unless configured otherwise, the same as in the chart
+	DefaultMaxSize = 32 << 20
+	// maxRedirects is the number of redirects followed, like http.Client does by default
+	maxRedirects = 10
+)
+
+// RejectedError is returned if a URL was rejected by the Policy
+type RejectedError struct {
+	URL    string
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("fetching %s was rejected: %s", e.URL, e.Reason)
+}
+
+// IsRejected returns the RejectedError err was caused by, if any
+func IsRejected(err error) (*RejectedError, bool) {
+	for err != nil {
+		switch e := err.(type) {
+		case *RejectedError:
+			return e, true
+		case *url.Error:
+			err = e.Err
+		case *net.OpError:
+			err = e.Err
+		default:
+			cause := errors.Cause(err)
+			if cause == err {
+				return nil, false
+			}
+			err = cause
+		}
+	}
+	return nil, false
+}
+
+// Policy restricts where reports are fetched from. PipelineActivities can be created by anyone running pipelines, so
+// without it they could make the analyzer request internal endpoints, such as cloud metadata, on their behalf.
+//
+// If neither Hosts nor Networks are set reports can be fetched from any public address. Otherwise reports can only be
+// fetched from the hosts in Hosts and addresses in Networks. Loopback, private and link-local addresses are blocked
+// unless they are in Networks or AllowPrivate is set, except that the hosts in Hosts may resolve to private addresses.
+// The addresses are checked when connecting, so redirects and DNS changes can't get around them. Reports are always
+// fetched directly, ignoring HTTP_PROXY and HTTPS_PROXY, as the addresses couldn't be checked through a proxy
+type Policy struct {
+	// Hosts are the host names reports may be fetched from. *.example.com matches every subdomain of example.com
+	Hosts []string
+	// Networks are the addresses reports may be fetched from
+	Networks []*net.IPNet
+	// AllowPrivate allows fetching reports from any loopback, private and link-local address
+	AllowPrivate bool
+	// MaxSize is the maximum size of a report in bytes, 0 for no limit
+	MaxSize int64
+
+	// trusted are hosts configured by the administrator, such as the S3 endpoint, which are treated as if they were in
+	// Hosts without restricting the other hosts
+	trusted []string
+}
+
+// PolicyFromEnv reads the policy from the environment, see ConfigFromEnv
+func PolicyFromEnv() (Policy, error) {
+	policy := Policy{
+		AllowPrivate: os.Getenv("SPOTBUGS_FETCH_ALLOW_PRIVATE") == "true",
+		MaxSize:      DefaultMaxSize,
+	}
+	for _, item := range strings.Split(os.Getenv("SPOTBUGS_FETCH_ALLOW"), ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		switch {
+		case item == "":
+		case strings.Contains(item, "/"):
+			_, network, err := net.ParseCIDR(item)
+			if err != nil {
+				return policy, errors.Errorf("invalid network %q", item)
+			}
+			policy.Networks = append(policy.Networks, network)
+		case net.ParseIP(item) != nil:
+			ip := net.ParseIP(item)
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			policy.Networks = append(policy.Networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		default:
+			policy.Hosts = append(policy.Hosts, item)
+		}
+	}
+	if value := os.Getenv("SPOTBUGS_FETCH_MAX_SIZE"); value != "" {
+		size, err := resource.ParseQuantity(value)
+		if err != nil || size.Value() < 0 {
+			return policy, errors.Errorf("invalid maximum report size %q", value)
+		}
+		policy.MaxSize = size.Value()
+	}
+	return policy, nil
+}
+
+// trust returns a copy of the policy which also allows host
+func (p Policy) trust(host string) Policy {
+	p.trusted = append(append([]string(nil), p.trusted...), strings.ToLower(host))
+	return p
+}
+
+// restricted returns true if reports can only be fetched from the hosts and addresses listed
+func (p Policy) restricted() bool {
+	return len(p.Hosts) > 0 || len(p.Networks) > 0
+}
+
+// hostAllowed returns true if host is listed in Hosts or trusted
+func (p Policy) hostAllowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, list := range [][]string{p.Hosts, p.trusted} {
+		for _, allowed := range list {
+			if allowed == host || strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkURL rejects URLs which can't be allowed whatever their host resolves to
+func (p Policy) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return &RejectedError{URL: u.String(), Reason: "only http and https are allowed"}
+	}
+	host := u.Hostname()
+	if p.restricted() && !p.hostAllowed(host) && net.ParseIP(host) == nil && len(p.Networks) == 0 {
+		return &RejectedError{URL: u.String(), Reason: fmt.Sprintf("host %s isn't allowed", host)}
+	}
+	return nil
+}
+
+// checkIP returns the reason ip of host isn't allowed, or an empty string if it is
+func (p Policy) checkIP(host string, ip net.IP) string {
+	// IPv4-mapped IPv6 addresses reach the IPv4 address they map, so they are checked as such
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	for _, network := range p.Networks {
+		if network.Contains(ip) {
+			return ""
+		}
+	}
+	hostAllowed := p.hostAllowed(host)
+	switch {
+	case p.restricted() && !hostAllowed:
+		return fmt.Sprintf("address %s of %s isn't allowed", ip, host)
+	case p.AllowPrivate:
+		return ""
+	case ip.IsUnspecified() || ip.IsMulticast() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast():
+		return fmt.Sprintf("address %s of %s is link-local or reserved", ip, host)
+	case !hostAllowed && (ip.IsLoopback() || isPrivate(ip)):
+		return fmt.Sprintf("address %s of %s is private", ip, host)
+	}
+	return ""
+}
+
+// privateNetworks are the private, shared and reserved address ranges, in addition to loopback and link-local
+// addresses. 0.0.0.0/8 reaches the local host on most systems. The NAT64 prefix 64:ff9b::/96 is blocked as a whole, as
+// it may translate to any IPv4 address, private ones included
+var privateNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"198.18.0.0/15",
+	"255.255.255.255/32",
+	"fc00::/7",
+	"64:ff9b::/96",
+)
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func isPrivate(ip net.IP) bool {
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialContext resolves the host of addr and connects to the first of its addresses the policy allows
+func (p Policy) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	var reason string
+	for _, address := range addresses {
+		if reason = p.checkIP(host, address.IP); reason != "" {
+			continue
+		}
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(address.IP.String(), port))
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	}
+	if reason == "" {
+		reason = "host " + host + " has no addresses"
+	}
+	return nil, &RejectedError{URL: addr, Reason: reason}
+}
+
+// checkRedirect validates redirects like the first request, in addition to limiting their number
+func (p Policy) checkRedirect(request *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	return p.checkURL(request.URL)
+}
+
+// limit returns a reader which fails once more than MaxSize bytes have been read from body
+func (p Policy) limit(location string, body io.ReadCloser) io.ReadCloser {
+	if p.MaxSize <= 0 {
+		return body
+	}
+	return &limitedReader{ReadCloser: body, location: location, remaining: p.MaxSize}
+}
+
+type limitedReader struct {
+	io.ReadCloser
+	location  string
+	remaining int64
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, r.exceeded()
+	}
+	// Read one more byte than allowed, to tell a report of exactly the maximum size from a larger one
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return n + int(r.remaining), r.exceeded()
+	}
+	return n, err
+}
+
+func (r *limitedReader) exceeded() error {
+	return &RejectedError{URL: r.location, Reason: "the report exceeds the maximum size"}
+}
@@ -0,0 +1,174 @@
+package fetch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestCheckIP(t *testing.T) {
+	restricted := Policy{
+		Hosts:    []string{"reports.example.com", "*.artifacts.example.com"},
+		Networks: parseNetworks("203.0.113.0/24"),
+	}
+	tests := []struct {
+		policy  Policy
+		host    string
+		ip      string
+		allowed bool
+	}{
+		{Policy{}, "reports.example.com", "93.184.216.34", true},
+		{Policy{}, "reports.example.com", "2606:2800:220:1::1", true},
+		{Policy{}, "localhost", "127.0.0.1", false},
+		{Policy{}, "localhost", "::1", false},
+		{Policy{}, "zero", "0.0.0.0", false},
+		{Policy{}, "zero", "0.1.2.3", false},
+		{Policy{}, "metadata", "169.254.169.254", false},
+		{Policy{}, "internal", "10.1.2.3", false},
+		{Policy{}, "internal", "172.16.0.1", false},
+		{Policy{}, "internal", "172.31.255.255", false},
+		{Policy{}, "internal", "192.168.1.1", false},
+		{Policy{}, "internal", "100.64.0.1", false},
+		{Policy{}, "benchmark", "198.18.0.1", false},
+		{Policy{}, "benchmark", "198.19.255.255", false},
+		{Policy{}, "internal", "fd00::1", false},
+		{Policy{}, "internal", "fe80::1", false},
+		{Policy{}, "multicast", "224.0.0.1", false},
+		{Policy{}, "broadcast", "255.255.255.255", false},
+		{Policy{}, "broadcast", "::ffff:255.255.255.255", false},
+		// NAT64 addresses embed an IPv4 address in their last 32 bits
+		{Policy{}, "nat64", "64:ff9b::a01:203", false},
+		{Policy{}, "nat64", "64:ff9b::7f00:1", false},
+		{Policy{}, "nat64", "64:ff9b::5db8:d822", false},
+		{Policy{AllowPrivate: true}, "nat64", "64:ff9b::a01:203", true},
+		{Policy{}, "public", "172.32.0.1", true},
+		{Policy{}, "public", "198.20.0.1", true},
+		// IPv4-mapped IPv6 addresses are checked as the IPv4 address they map
+		{Policy{}, "mapped", "::ffff:127.0.0.1", false},
+		{Policy{}, "mapped", "::ffff:10.1.2.3", false},
+		{Policy{}, "mapped", "::ffff:169.254.169.254", false},
+		{Policy{}, "mapped", "::ffff:198.18.0.1", false},
+		{Policy{}, "mapped", "::ffff:93.184.216.34", true},
+		{Policy{AllowPrivate: true}, "internal", "10.1.2.3", true},
+		{Policy{AllowPrivate: true}, "localhost", "127.0.0.1", true},
+		{Policy{Networks: parseNetworks("10.0.0.0/8")}, "internal", "10.1.2.3", true},
+		{Policy{Networks: parseNetworks("10.0.0.0/8")}, "internal", "::ffff:10.1.2.3", true},
+		{Policy{Networks: parseNetworks("10.0.0.0/8")}, "public", "93.184.216.34", false},
+		{restricted, "reports.example.com", "93.184.216.34", true},
+		{restricted, "reports.example.com", "10.1.2.3", true},
+		{restricted, "REPORTS.EXAMPLE.COM.", "93.184.216.34", true},
+		{restricted, "eu.artifacts.example.com", "93.184.216.34", true},
+		{restricted, "artifacts.example.com", "93.184.216.34", false},
+		{restricted, "other.example.com", "93.184.216.34", false},
+		{restricted, "other.example.com", "203.0.113.7", true},
+		{restricted, "reports.example.com", "169.254.169.254", false},
+		{Policy{}.trust("minio.storage"), "minio.storage", "10.1.2.3", true},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s=%s", test.host, test.ip), func(t *testing.T) {
+			ip := net.ParseIP(test.ip)
+			if ip == nil {
+				t.Fatalf("invalid address %s", test.ip)
+			}
+			reason := test.policy.checkIP(test.host, ip)
+			if allowed := reason == ""; allowed != test.allowed {
+				t.Errorf("got allowed %t (%s), want %t", allowed, reason, test.allowed)
+			}
+		})
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	restricted := Policy{Hosts: []string{"reports.example.com"}}
+	tests := []struct {
+		policy  Policy
+		url     string
+		allowed bool
+	}{
+		{Policy{}, "https://reports.example.com/a.xml", true},
+		{Policy{}, "http://reports.example.com/a.xml", true},
+		{Policy{}, "ftp://reports.example.com/a.xml", false},
+		{Policy{}, "file:///etc/passwd", false},
+		{restricted, "https://reports.example.com/a.xml", true},
+		{restricted, "https://other.example.com/a.xml", false},
+		// Addresses are checked when connecting
+		{restricted, "https://10.1.2.3/a.xml", true},
+		{Policy{Networks: parseNetworks("10.0.0.0/8")}, "https://other.example.com/a.xml", true},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			u, err := url.Parse(test.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = test.policy.checkURL(u)
+			if allowed := err == nil; allowed != test.allowed {
+				t.Errorf("got error %v, want allowed %t", err, test.allowed)
+			}
+		})
+	}
+}
+
+func TestFetchRejectsLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testReport))
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL + "/report.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = New(Config{Timeout: DefaultTimeout}).Fetch(u)
+	if _, ok := IsRejected(err); !ok {
+		t.Fatalf("got error %v, want the loopback address to be rejected", err)
+	}
+
+	body, err := New(Config{Timeout: DefaultTimeout, Policy: Policy{AllowPrivate: true}}).Fetch(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != testReport {
+		t.Errorf("got %q, want the report", data)
+	}
+}
+
+func TestTransportIgnoresProxy(t *testing.T) {
+	// The address of the report couldn't be checked through a proxy
+	if newTransport(nil, Policy{}.dialContext).Proxy != nil {
+		t.Error("got a transport using a proxy")
+	}
+}
+
+func TestFetchMaxSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testReport))
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL + "/report.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, maxSize := range []int64{int64(len(testReport)), int64(len(testReport)) - 1} {
+		fetcher := New(Config{Timeout: DefaultTimeout, Policy: Policy{AllowPrivate: true, MaxSize: maxSize}})
+		body, err := fetcher.Fetch(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ioutil.ReadAll(body)
+		body.Close()
+		_, rejected := IsRejected(err)
+		if want := maxSize < int64(len(testReport)); rejected != want {
+			t.Errorf("got error %v with a maximum size of %d bytes, want rejected %t", err, maxSize, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package fetch
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultRate is the number of requests per second sent to a host unless configured otherwise
+	DefaultRate = 10
+	// DefaultBurst is the number of requests which may be sent to a host at once unless configured otherwise
+	DefaultBurst = 20
+)
+
+// RateLimit limits the requests sent to every host, so a burst of builds doesn't overload the storage they publish
+// their reports to
+type RateLimit struct {
+	// PerSecond is the number of requests per second sent to a host, 0 for no limit
+	PerSecond float64
+	// Burst is the number of requests which may be sent to a host at once, before the rate applies
+	Burst int
+}
+
+// rateLimitTransport delays requests to hosts which exceed their rate. The delay counts towards the timeout of the
+// request. Hosts come from the URLs attached to PipelineActivities, so the limiters of hosts which have been idle for
+// long enough to be back at their full burst are forgotten, a new limiter behaving the same
+type rateLimitTransport struct {
+	limit RateLimit
+	next  http.RoundTripper
+
+	mu        sync.Mutex
+	limiters  map[string]*hostLimiter
+	lastSweep time.Time
+}
+
+// hostLimiter is the limiter of a host
+type hostLimiter struct {
+	*rate.Limiter
+	// waiting is the number of requests waiting for the limiter, lastUsed when the last of them was let through
+	waiting  int
+	lastUsed time.Time
+}
+
+func newRateLimitTransport(limit RateLimit, next http.RoundTripper) *rateLimitTransport {
+	return &rateLimitTransport{
+		limit:     limit,
+		next:      next,
+		limiters:  make(map[string]*hostLimiter),
+		lastSweep: time.Now(),
+	}
+}
+
+func (t *rateLimitTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	limiter := t.acquire(request.URL.Host)
+	err := limiter.Wait(request.Context())
+	t.release(limiter)
+	if err != nil {
+		return nil, errors.Wrapf(err, "rate limit of %s", request.URL.Host)
+	}
+	return t.next.RoundTrip(request)
+}
+
+// acquire returns the limiter of host, creating it on first use
+func (t *rateLimitTransport) acquire(host string) *hostLimiter {
+	host = strings.ToLower(host)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	limiter, ok := t.limiters[host]
+	if !ok {
+		t.sweep(time.Now())
+		limiter = &hostLimiter{Limiter: rate.NewLimiter(rate.Limit(t.limit.PerSecond), t.burst())}
+		t.limiters[host] = limiter
+	}
+	limiter.waiting++
+	return limiter
+}
+
+func (t *rateLimitTransport) release(limiter *hostLimiter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	limiter.waiting--
+	limiter.lastUsed = time.Now()
+}
+
+func (t *rateLimitTransport) burst() int {
+	if t.limit.Burst < 1 {
+		return 1
+	}
+	return t.limit.Burst
+}
+
+// sweep forgets the limiters which nobody waits for and which have been idle for long enough to be back at their full
+// burst, at most once in that time
+func (t *rateLimitTransport) sweep(now time.Time) {
+	refill := time.Duration(float64(t.burst()) / t.limit.PerSecond * float64(time.Second))
+	if now.Sub(t.lastSweep) < refill {
+		return
+	}
+	t.lastSweep = now
+	for host, limiter := range t.limiters {
+		if limiter.waiting == 0 && now.Sub(limiter.lastUsed) >= refill {
+			delete(t.limiters, host)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimitTransport(t *testing.T) {
+	transport := newRateLimitTransport(RateLimit{PerSecond: 20, Burst: 2}, &recordingTransport{})
+	get := func(host string) {
+		request, err := http.NewRequest(http.MethodGet, "https://"+host+"/spotbugsXml.xml", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := transport.RoundTrip(request); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The burst is let through at once, the following requests at the rate
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		get("reports.example.com")
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("got 4 requests sent in %s, want the last 2 to be delayed by 50ms each", elapsed)
+	}
+	// Other hosts have limiters of their own
+	start = time.Now()
+	get("other.example.com")
+	get("other.example.com")
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Errorf("got requests to other hosts delayed by %s", elapsed)
+	}
+
+	// Limiters idle for the time the burst takes to refill are forgotten
+	for i := 0; i < 50; i++ {
+		get(fmt.Sprintf("host-%d.example.com", i))
+	}
+	time.Sleep(150 * time.Millisecond)
+	get("new.example.com")
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	if len(transport.limiters) != 1 {
+		t.Errorf("got %d limiters, want only the one of the new host", len(transport.limiters))
+	}
+}
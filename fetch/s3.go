@@ -0,0 +1,184 @@
+package fetch
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultS3Region is the region signed for unless configured otherwise. MinIO accepts it by default
+	defaultS3Region = "us-east-1"
+	// emptyPayloadHash is the SHA-256 of the empty body of GET requests
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Config configures access to S3 or an S3-compatible store
+type S3Config struct {
+	// Endpoint is the URL of an S3-compatible store such as MinIO. Buckets are addressed by path on it. If empty,
+	// buckets are addressed on AWS by host
+	Endpoint string
+	Region   string
+	// AccessKeyID and SecretAccessKey sign the requests, which are anonymous if they are empty
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// S3ConfigFromEnv reads the S3 configuration from the environment, see ConfigFromEnv
+func S3ConfigFromEnv() S3Config {
+	config := S3Config{
+		Endpoint:        os.Getenv("SPOTBUGS_S3_ENDPOINT"),
+		Region:          os.Getenv("SPOTBUGS_S3_REGION"),
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if config.Region == "" {
+		config.Region = defaultS3Region
+	}
+	return config
+}
+
+// S3Fetcher retrieves reports at s3://<bucket>/<key> from S3 or an S3-compatible store
+type S3Fetcher struct {
+	Config S3Config
+	Client *http.Client
+}
+
+// Fetch retrieves the object at s3://<bucket>/<key>
+func (f *S3Fetcher) Fetch(u *url.URL) (io.ReadCloser, error) {
+	bucket := u.Host
+	key := strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, errors.Errorf("S3 URL %s must be s3://<bucket>/<key>", u)
+	}
+	objectURL, err := f.objectURL(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest(http.MethodGet, objectURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if f.Config.AccessKeyID != "" {
+		f.sign(request, time.Now().UTC())
+	}
+	response, err := f.Client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	body, err := checkResponse(response)
+	return body, errors.Wrapf(err, "fetching %s", u)
+}
+
+// objectURL returns the http(s) URL of the object
+func (f *S3Fetcher) objectURL(bucket, key string) (*url.URL, error) {
+	path := "/" + key
+	if f.Config.Endpoint == "" {
+		host := "s3.amazonaws.com"
+		if f.Config.Region != "" && f.Config.Region != defaultS3Region {
+			host = "s3." + f.Config.Region + ".amazonaws.com"
+		}
+		return &url.URL{Scheme: "https", Host: bucket + "." + host, Path: path, RawPath: uriEncodePath(path)}, nil
+	}
+	endpoint, err := url.Parse(f.Config.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, errors.Errorf("invalid S3 endpoint %q", f.Config.Endpoint)
+	}
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + "/" + bucket + path
+	endpoint.RawPath = uriEncodePath(endpoint.Path)
+	return endpoint, nil
+}
+
+// uriEncodePath encodes every segment of path the way Signature Version 4 expects for the canonical URI: every byte
+// except the unreserved characters of RFC 3986 is percent-encoded, while EscapedPath leaves e.g. +, =, $ and @ as they
+// are. The object URL is sent with the same encoding, so the path S3 receives matches the path that was signed
+func uriEncodePath(path string) string {
+	const hex = "0123456789ABCDEF"
+	var encoded strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			encoded.WriteByte(c)
+		default:
+			encoded.WriteByte('%')
+			encoded.WriteByte(hex[c>>4])
+			encoded.WriteByte(hex[c&15])
+		}
+	}
+	return encoded.String()
+}
+
+// sign adds an AWS Signature Version 4 to the request
+func (f *S3Fetcher) sign(request *http.Request, now time.Time) {
+	date := now.Format("20060102")
+	timestamp := now.Format("20060102T150405Z")
+	request.Header.Set("X-Amz-Date", timestamp)
+	request.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	if f.Config.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", f.Config.SessionToken)
+	}
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(request.Header.Get(name))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		uriEncodePath(request.URL.Path),
+		request.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+	scope := date + "/" + f.Config.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		timestamp,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+f.Config.SecretAccessKey), date)
+	key = hmacSHA256(key, f.Config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+f.Config.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"strings"
+
+	jenkinsv1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	"github.com/pkg/errors"
+
+	"github.com/jenkins-x/ext-spotbugs/fetch"
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+// filterConfig selects the SpotBugs filter files applied to reports before they are summarised, so bugs can be
+// suppressed without running SpotBugs again. The filters are read every time a report is summarised, filters fetched
+// over http(s) are revalidated with a conditional request
+type filterConfig struct {
+	// Include and Exclude are the locations of the filters, either files or URLs of a scheme supported by the
+	// fetchers. Only the bugs matching one of the include filters, if there are any, and none of the exclude filters
+	// are kept
+	Include []string
+	Exclude []string
+}
+
+// filterConfigFromEnv reads the filters applied to every report from the environment:
+//
+//	SPOTBUGS_INCLUDE_FILTERS comma separated files or URLs of filters selecting the bugs to keep
+//	SPOTBUGS_EXCLUDE_FILTERS comma separated files or URLs of filters selecting the bugs to suppress
+func filterConfigFromEnv() filterConfig {
+	return filterConfig{
+		Include: splitList(os.Getenv("SPOTBUGS_INCLUDE_FILTERS")),
+		Exclude: splitList(os.Getenv("SPOTBUGS_EXCLUDE_FILTERS")),
+	}
+}
+
+// empty returns true if there are no filters
+func (c filterConfig) empty() bool {
+	return len(c.Include) == 0 && len(c.Exclude) == 0
+}
+
+// forActivity adds the filters act is annotated with, e.g. those of its repository. Annotated filters must be URLs,
+// they are fetched under the same policy as reports and never read from the analyzer's own files. They only apply to
+// the summary, the quality gate ignores them
+func (c filterConfig) forActivity(act *jenkinsv1.PipelineActivity) (filterConfig, error) {
+	include := splitList(act.Annotations[annotationIncludeFilters])
+	exclude := splitList(act.Annotations[annotationExcludeFilters])
+	for _, location := range append(include, exclude...) {
+		if !strings.Contains(location, "://") {
+			return c, errors.Errorf("filter %s of PipelineActivity %s is not a URL", location, act.Name)
+		}
+	}
+	return filterConfig{
+		Include: append(append([]string(nil), c.Include...), include...),
+		Exclude: append(append([]string(nil), c.Exclude...), exclude...),
+	}, nil
+}
+
+// hasAnnotatedFilters returns true if act is annotated with filters of its own
+func hasAnnotatedFilters(act *jenkinsv1.PipelineActivity) bool {
+	return len(splitList(act.Annotations[annotationIncludeFilters])) > 0 ||
+		len(splitList(act.Annotations[annotationExcludeFilters])) > 0
+}
+
+// apply returns bugCollection with the bugs the filters suppress removed, fetching filters given as URLs with fetcher
+func (c filterConfig) apply(bugCollection findbugs.BugCollection,
+	fetcher fetch.ReportFetcher) (findbugs.BugCollection, error) {
+	if c.empty() {
+		return bugCollection, nil
+	}
+	include, err := loadFilters(c.Include, fetcher)
+	if err != nil {
+		return bugCollection, err
+	}
+	exclude, err := loadFilters(c.Exclude, fetcher)
+	if err != nil {
+		return bugCollection, err
+	}
+	return findbugs.ApplyFilters(bugCollection, include, exclude), nil
+}
+
+func loadFilters(locations []string, fetcher fetch.ReportFetcher) ([]*findbugs.Filter, error) {
+	filters := make([]*findbugs.Filter, 0, len(locations))
+	for _, location := range locations {
+		filter, err := loadFilter(location, fetcher)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read filter %s", location)
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// loadFilter reads the filter at location, which is either a URL fetched with fetcher or a file
+func loadFilter(location string, fetcher fetch.ReportFetcher) (*findbugs.Filter, error) {
+	if !strings.Contains(location, "://") {
+		return findbugs.ParseFilterFile(location)
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	body, err := fetcher.Fetch(u)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return findbugs.ParseFilter(body)
+}
@@ -0,0 +1,41 @@
+package findbugs
+
+import "strings"
+
+// Key identifies a bug across reports, using the instance hash when SpotBugs provides one
+func (b BugInstance) Key() string {
+	if b.InstanceHash != "" {
+		return b.InstanceHash
+	}
+	return b.Type + "|" + b.Class.ClassName + "|" + b.Method.Name + b.Method.Signature + "|" + b.Field.Name
+}
+
+// Location returns the source line the bug is reported at, falling back to the location of its method or class if
+// SpotBugs didn't report a specific line
+func (b BugInstance) Location() SourceLine {
+	lines := []SourceLine{b.SourceLine, b.Method.SourceLine, b.Field.SourceLine, b.Class.SourceLine}
+	for _, l := range lines {
+		if l.Start > 0 {
+			return l
+		}
+	}
+	for _, l := range lines {
+		if l.SourcePath != "" || l.SourceFile != "" {
+			return l
+		}
+	}
+	return b.SourceLine
+}
+
+// Package returns the Java package of the class the bug was found in
+func (b BugInstance) Package() string {
+	return PackageOf(b.Class.ClassName)
+}
+
+// PackageOf returns the package of the fully qualified className, empty for the default package
+func PackageOf(className string) string {
+	if i := strings.LastIndex(className, "."); i >= 0 {
+		return className[:i]
+	}
+	return ""
+}
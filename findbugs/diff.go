@@ -0,0 +1,37 @@
+package findbugs
+
+// Diff is the difference between the bugs of two reports
+type Diff struct {
+	// New are the bugs only found in the head report
+	New []BugInstance
+	// Fixed are the bugs only found in the base report
+	Fixed []BugInstance
+}
+
+// Compare matches the bugs of base and head by their identity and returns the bugs which have been introduced and
+// fixed. SpotBugs computes instance hashes which are stable across builds; if several bugs share an identity only the
+// difference in their number is reported.
+func Compare(base, head BugCollection) Diff {
+	return Diff{
+		New:   subtract(head.BugInstance, base.BugInstance),
+		Fixed: subtract(base.BugInstance, head.BugInstance),
+	}
+}
+
+// subtract returns the bugs in a which have no counterpart in b
+func subtract(a, b []BugInstance) []BugInstance {
+	counts := make(map[string]int)
+	for _, bug := range b {
+		counts[bug.Key()]++
+	}
+	var result []BugInstance
+	for _, bug := range a {
+		key := bug.Key()
+		if counts[key] > 0 {
+			counts[key]--
+			continue
+		}
+		result = append(result, bug)
+	}
+	return result
+}
@@ -0,0 +1,511 @@
+package findbugs
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotFilter is returned by ParseFilter if the XML document isn't a FindBugs or SpotBugs filter
+var ErrNotFilter = errors.New("not a FindBugs or SpotBugs filter")
+
+// Filter is a FindBugsFilter file, as passed to SpotBugs with -include or -exclude. A bug matches the filter if it
+// matches one of its Match elements
+type Filter struct {
+	matches []matcher
+}
+
+// matcher is an element of a filter
+type matcher interface {
+	match(b BugInstance) bool
+}
+
+// filterElement is an element of a filter as it is written, before it is compiled into a matcher
+type filterElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr      `xml:",any,attr"`
+	Children []filterElement `xml:",any"`
+}
+
+func (e filterElement) attr(name string) (string, bool) {
+	for _, a := range e.Attrs {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// ParseFilter decodes a FindBugsFilter from r. The elements Match, And, Or and Not and the clauses Bug, BugPattern,
+// BugCode, Class, Package, Method, Field, Source, Priority, Confidence and Rank are supported, other elements fail the
+// filter rather than being ignored, which would widen what it matches. Names starting with ~ are regular expressions
+// which must match the whole name
+func ParseFilter(r io.Reader) (*Filter, error) {
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local != "FindBugsFilter" {
+			return nil, ErrNotFilter
+		}
+		var root filterElement
+		err = decoder.DecodeElement(&root, &start)
+		if err != nil {
+			return nil, err
+		}
+		filter := &Filter{}
+		for _, child := range root.Children {
+			if child.XMLName.Local != "Match" {
+				return nil, errors.Errorf("unexpected element %s in FindBugsFilter, expected Match", child.XMLName.Local)
+			}
+			m, err := compileMatch(child)
+			if err != nil {
+				return nil, err
+			}
+			filter.matches = append(filter.matches, m)
+		}
+		return filter, nil
+	}
+}
+
+// ParseFilterFile decodes the FindBugsFilter at path
+func ParseFilterFile(path string) (*Filter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseFilter(f)
+}
+
+// Matches returns true if b matches one of the Match elements of the filter
+func (f *Filter) Matches(b BugInstance) bool {
+	for _, m := range f.matches {
+		if m.match(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyFilters returns bugCollection with only the bugs which match one of the include filters, if there are any, and
+// none of the exclude filters, like SpotBugs does when run with -include and -exclude. The totals of FindBugsSummary
+// and the bug counts of its PackageStats are reduced by the bugs removed
+func ApplyFilters(bugCollection BugCollection, include, exclude []*Filter) BugCollection {
+	result := bugCollection
+	result.BugInstance = make([]BugInstance, 0, len(bugCollection.BugInstance))
+	removed := make([]BugInstance, 0)
+	for _, b := range bugCollection.BugInstance {
+		if keep(b, include, exclude) {
+			result.BugInstance = append(result.BugInstance, b)
+		} else {
+			removed = append(removed, b)
+		}
+	}
+	if len(removed) == 0 {
+		return result
+	}
+	summary := &result.FindBugsSummary
+	// The stats are copied, so bugCollection keeps its counts
+	summary.PackageStats = make([]PackageStats, len(bugCollection.FindBugsSummary.PackageStats))
+	for i, stats := range bugCollection.FindBugsSummary.PackageStats {
+		stats.ClassStats = append([]ClassStats(nil), stats.ClassStats...)
+		summary.PackageStats[i] = stats
+	}
+	for _, b := range removed {
+		summary.TotalBugs--
+		switch b.Priority {
+		case PriorityHigh:
+			summary.HighPriority--
+		case PriorityNormal:
+			summary.NormalPriority--
+		case PriorityLow:
+			summary.LowPriority--
+		case PriorityExperimental:
+			summary.ExpPriority--
+		case PriorityIgnore:
+			summary.IgnorePriority--
+		}
+		for i := range summary.PackageStats {
+			stats := &summary.PackageStats[i]
+			if stats.Package != b.Package() {
+				continue
+			}
+			stats.TotalBugs--
+			for j := range stats.ClassStats {
+				if stats.ClassStats[j].Class == b.Class.ClassName {
+					stats.ClassStats[j].Bugs--
+				}
+			}
+		}
+	}
+	return result
+}
+
+func keep(b BugInstance, include, exclude []*Filter) bool {
+	included := len(include) == 0
+	for _, f := range include {
+		if f.Matches(b) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, f := range exclude {
+		if f.Matches(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// allOf matches if all of its matchers match, as a Match or And element does
+type allOf []matcher
+
+func (m allOf) match(b BugInstance) bool {
+	for _, child := range m {
+		if !child.match(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// anyOf matches if one of its matchers matches, as an Or element does
+type anyOf []matcher
+
+func (m anyOf) match(b BugInstance) bool {
+	for _, child := range m {
+		if child.match(b) {
+			return true
+		}
+	}
+	return false
+}
+
+type not struct {
+	matcher
+}
+
+func (m not) match(b BugInstance) bool {
+	return !m.matcher.match(b)
+}
+
+// matcherFunc adapts a function to a matcher
+type matcherFunc func(b BugInstance) bool
+
+func (f matcherFunc) match(b BugInstance) bool {
+	return f(b)
+}
+
+// compileMatch compiles a Match element. Like old versions of FindBugs, the class it applies to may be given by the
+// class and classregex attributes of the element
+func compileMatch(e filterElement) (matcher, error) {
+	var result allOf
+	if class, ok := e.attr("class"); ok {
+		result = append(result, matcherFunc(func(b BugInstance) bool {
+			return b.Class.ClassName == class
+		}))
+	}
+	if classRegex, ok := e.attr("classregex"); ok {
+		name, err := compileName("~" + classRegex)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, matcherFunc(func(b BugInstance) bool {
+			return name(b.Class.ClassName)
+		}))
+	}
+	children, err := compileChildren(e)
+	if err != nil {
+		return nil, err
+	}
+	return append(result, children...), nil
+}
+
+func compileChildren(e filterElement) ([]matcher, error) {
+	result := make([]matcher, 0, len(e.Children))
+	for _, child := range e.Children {
+		m, err := compile(child)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// compile compiles a clause of a Match element
+func compile(e filterElement) (matcher, error) {
+	switch e.XMLName.Local {
+	case "And", "Or", "Not":
+		children, err := compileChildren(e)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case e.XMLName.Local == "And":
+			return allOf(children), nil
+		case e.XMLName.Local == "Or":
+			return anyOf(children), nil
+		case len(children) != 1:
+			return nil, errors.Errorf("Not must contain exactly one element but contains %d", len(children))
+		}
+		return not{children[0]}, nil
+	case "Bug":
+		return compileBug(e)
+	case "BugPattern":
+		return compileAttr(e, "name", func(b BugInstance) string { return b.Type })
+	case "BugCode":
+		return compileAttr(e, "name", func(b BugInstance) string { return b.Abbrev })
+	case "Class":
+		return compileAttr(e, "name", func(b BugInstance) string { return b.Class.ClassName })
+	case "Package":
+		return compileAttr(e, "name", BugInstance.Package)
+	case "Source":
+		value, err := requiredAttr(e, "name")
+		if err != nil {
+			return nil, err
+		}
+		name, err := compileName(value)
+		if err != nil {
+			return nil, err
+		}
+		return matcherFunc(func(b BugInstance) bool {
+			location := b.Location()
+			return name(location.SourceFile) || name(location.SourcePath)
+		}), nil
+	case "Method":
+		return compileMethod(e)
+	case "Field":
+		return compileField(e)
+	case "Priority", "Confidence":
+		value, err := intAttr(e)
+		if err != nil {
+			return nil, err
+		}
+		return matcherFunc(func(b BugInstance) bool {
+			return b.Priority == value
+		}), nil
+	case "Rank":
+		value, err := intAttr(e)
+		if err != nil {
+			return nil, err
+		}
+		// As in SpotBugs, a rank matches the bugs of that rank and the less scary ones
+		return matcherFunc(func(b BugInstance) bool {
+			return b.Rank > 0 && b.Rank >= value
+		}), nil
+	}
+	return nil, errors.Errorf("unsupported filter element %s", e.XMLName.Local)
+}
+
+// compileBug compiles a Bug element. Its pattern, code and category attributes are comma separated lists, a bug
+// matches if it matches an entry of any of them
+func compileBug(e filterElement) (matcher, error) {
+	fields := map[string]func(b BugInstance) string{
+		"pattern":  func(b BugInstance) string { return b.Type },
+		"code":     func(b BugInstance) string { return b.Abbrev },
+		"category": func(b BugInstance) string { return b.Category },
+	}
+	var result anyOf
+	for _, attr := range []string{"pattern", "code", "category"} {
+		value, ok := e.attr(attr)
+		if !ok {
+			continue
+		}
+		field := fields[attr]
+		for _, entry := range strings.Split(value, ",") {
+			name, err := compileName(strings.TrimSpace(entry))
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, matcherFunc(func(b BugInstance) bool {
+				return name(field(b))
+			}))
+		}
+	}
+	if len(result) == 0 {
+		return nil, errors.New("Bug must have a pattern, code or category")
+	}
+	return result, nil
+}
+
+// compileAttr compiles an element matching the name in its attribute attr against the value field returns
+func compileAttr(e filterElement, attr string, field func(b BugInstance) string) (matcher, error) {
+	value, err := requiredAttr(e, attr)
+	if err != nil {
+		return nil, err
+	}
+	name, err := compileName(value)
+	if err != nil {
+		return nil, err
+	}
+	return matcherFunc(func(b BugInstance) bool {
+		return name(field(b))
+	}), nil
+}
+
+// compileMethod compiles a Method element. Its params are the comma separated Java types of the parameters, e.g.
+// int,java.lang.String, and returns the Java type of the result
+func compileMethod(e filterElement) (matcher, error) {
+	var result allOf
+	if value, ok := e.attr("name"); ok {
+		name, err := compileName(value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, matcherFunc(func(b BugInstance) bool {
+			return name(b.Method.Name)
+		}))
+	}
+	if value, ok := e.attr("params"); ok {
+		expected := strings.Replace(value, " ", "", -1)
+		result = append(result, matcherFunc(func(b BugInstance) bool {
+			params, _ := javaTypes(b.Method.Signature)
+			return strings.Join(params, ",") == expected
+		}))
+	}
+	if value, ok := e.attr("returns"); ok {
+		expected := strings.TrimSpace(value)
+		result = append(result, matcherFunc(func(b BugInstance) bool {
+			_, returns := javaTypes(b.Method.Signature)
+			return returns == expected
+		}))
+	}
+	if len(result) == 0 {
+		return nil, errors.New("Method must have a name, params or returns")
+	}
+	return result, nil
+}
+
+// compileField compiles a Field element, whose type is a Java type
+func compileField(e filterElement) (matcher, error) {
+	var result allOf
+	if value, ok := e.attr("name"); ok {
+		name, err := compileName(value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, matcherFunc(func(b BugInstance) bool {
+			return name(b.Field.Name)
+		}))
+	}
+	if value, ok := e.attr("type"); ok {
+		expected := strings.TrimSpace(value)
+		result = append(result, matcherFunc(func(b BugInstance) bool {
+			_, fieldType := javaTypes(b.Field.Signature)
+			return fieldType == expected
+		}))
+	}
+	if len(result) == 0 {
+		return nil, errors.New("Field must have a name or type")
+	}
+	return result, nil
+}
+
+func requiredAttr(e filterElement, name string) (string, error) {
+	value, ok := e.attr(name)
+	if !ok || value == "" {
+		return "", errors.Errorf("%s must have a %s", e.XMLName.Local, name)
+	}
+	return value, nil
+}
+
+func intAttr(e filterElement) (int, error) {
+	value, err := requiredAttr(e, "value")
+	if err != nil {
+		return 0, err
+	}
+	result, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, errors.Errorf("invalid value %q of %s", value, e.XMLName.Local)
+	}
+	return result, nil
+}
+
+// compileName returns a function matching value exactly, or as a regular expression if it starts with ~
+func compileName(value string) (func(string) bool, error) {
+	if value == "" {
+		return nil, errors.New("empty name in filter")
+	}
+	if !strings.HasPrefix(value, "~") {
+		return func(s string) bool {
+			return s == value
+		}, nil
+	}
+	re, err := regexp.Compile("^(?:" + value[1:] + ")$")
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid regular expression %s", value)
+	}
+	return re.MatchString, nil
+}
+
+// javaTypes converts a JVM signature to Java types, returning the parameter types and the return type of a method
+// signature, e.g. (I[Ljava/lang/String;)V, or just the type of a field signature
+func javaTypes(signature string) ([]string, string) {
+	params := make([]string, 0)
+	rest := signature
+	if strings.HasPrefix(rest, "(") {
+		rest = rest[1:]
+		for rest != "" && rest[0] != ')' {
+			var param string
+			param, rest = firstJavaType(rest)
+			params = append(params, param)
+		}
+		rest = strings.TrimPrefix(rest, ")")
+	}
+	result, _ := firstJavaType(rest)
+	return params, result
+}
+
+var primitiveTypes = map[byte]string{
+	'B': "byte",
+	'C': "char",
+	'D': "double",
+	'F': "float",
+	'I': "int",
+	'J': "long",
+	'S': "short",
+	'Z': "boolean",
+	'V': "void",
+}
+
+// firstJavaType converts the first type of signature, returning it and the rest of signature
+func firstJavaType(signature string) (string, string) {
+	dimensions := 0
+	for dimensions < len(signature) && signature[dimensions] == '[' {
+		dimensions++
+	}
+	signature = signature[dimensions:]
+	if signature == "" {
+		return "", ""
+	}
+	javaType, rest := primitiveTypes[signature[0]], signature[1:]
+	if signature[0] == 'L' {
+		end := strings.IndexByte(rest, ';')
+		if end < 0 {
+			end = len(rest)
+			rest += ";"
+		}
+		javaType, rest = strings.Replace(rest[:end], "/", ".", -1), rest[end+1:]
+	}
+	if javaType == "" {
+		// Not a valid signature, the rest can't be converted either
+		return signature, ""
+	}
+	return javaType + strings.Repeat("[]", dimensions), rest
+}
@@ -0,0 +1,311 @@
+package findbugs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The bugs the filters are tested against, modelled on the bugs SpotBugs reports
+var (
+	nullDeref = BugInstance{
+		Type:     "NP_NULL_ON_SOME_PATH",
+		Abbrev:   "NP",
+		Category: "CORRECTNESS",
+		Priority: PriorityHigh,
+		Rank:     4,
+		Class:    Class{ClassName: "com.example.Foo"},
+		Method:   Method{Name: "load", Signature: "(Ljava/lang/String;[I)Ljava/util/List;"},
+		SourceLine: SourceLine{
+			SourceFile: "Foo.java",
+			SourcePath: "com/example/Foo.java",
+			Start:      42,
+		},
+	}
+	exposeRep = BugInstance{
+		Type:     "EI_EXPOSE_REP",
+		Abbrev:   "EI",
+		Category: "MALICIOUS_CODE",
+		Priority: PriorityLow,
+		Rank:     18,
+		Class:    Class{ClassName: "com.example.Foo$Builder"},
+		Method:   Method{Name: "getData", Signature: "()[[B"},
+		Field:    Field{Name: "data", Signature: "[[B"},
+	}
+	unreadField = BugInstance{
+		Type:     "URF_UNREAD_FIELD",
+		Abbrev:   "URF",
+		Category: "PERFORMANCE",
+		Priority: PriorityNormal,
+		Rank:     20,
+		Class:    Class{ClassName: "com.example.db.Dao"},
+		Field:    Field{Name: "cache", Signature: "Ljava/util/Map$Entry;"},
+		SourceLine: SourceLine{
+			SourceFile: "Dao.java",
+			SourcePath: "com/example/db/Dao.java",
+		},
+	}
+	testBugs = []BugInstance{nullDeref, exposeRep, unreadField}
+)
+
+func parseTestFilter(t *testing.T, matches string) *Filter {
+	filter, err := ParseFilter(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<FindBugsFilter xmlns="https://github.com/spotbugs/filter/3.0.0">` + matches + `</FindBugsFilter>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filter
+}
+
+// matchingTypes returns the types of the bugs filter matches
+func matchingTypes(filter *Filter, bugs []BugInstance) []string {
+	result := make([]string, 0)
+	for _, b := range bugs {
+		if filter.Matches(b) {
+			result = append(result, b.Abbrev)
+		}
+	}
+	return result
+}
+
+func TestFilterMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		matches string
+		want    []string
+	}{
+		{"bug pattern", `<Match><Bug pattern="NP_NULL_ON_SOME_PATH"/></Match>`, []string{"NP"}},
+		{"bug codes", `<Match><Bug code="EI, URF"/></Match>`, []string{"EI", "URF"}},
+		{"bug category", `<Match><Bug category="PERFORMANCE"/></Match>`, []string{"URF"}},
+		{"bug pattern regex", `<Match><Bug pattern="~.*_EXPOSE_.*"/></Match>`, []string{"EI"}},
+		{"BugPattern", `<Match><BugPattern name="URF_UNREAD_FIELD"/></Match>`, []string{"URF"}},
+		{"BugCode", `<Match><BugCode name="NP"/></Match>`, []string{"NP"}},
+		{"class", `<Match><Class name="com.example.Foo"/></Match>`, []string{"NP"}},
+		{"nested class", `<Match><Class name="com.example.Foo$Builder"/></Match>`, []string{"EI"}},
+		{"class regex", `<Match><Class name="~com\.example\.Foo(\$.*)?"/></Match>`, []string{"NP", "EI"}},
+		// Regular expressions must match the whole name
+		{"partial regex", `<Match><Class name="~com\.example"/></Match>`, []string{}},
+		{"class attribute", `<Match class="com.example.db.Dao"/>`, []string{"URF"}},
+		{"classregex attribute", `<Match classregex=".*Foo.*"><Bug code="EI"/></Match>`, []string{"EI"}},
+		{"package", `<Match><Package name="com.example"/></Match>`, []string{"NP", "EI"}},
+		{"package regex", `<Match><Package name="~com\.example\..*"/></Match>`, []string{"URF"}},
+		{"source file", `<Match><Source name="Foo.java"/></Match>`, []string{"NP"}},
+		{"source path", `<Match><Source name="~.*/db/.*\.java"/></Match>`, []string{"URF"}},
+		{"method", `<Match><Method name="load"/></Match>`, []string{"NP"}},
+		{"method params", `<Match><Method params="java.lang.String, int[]"/></Match>`, []string{"NP"}},
+		{"method returns", `<Match><Method name="load" returns="java.util.List"/></Match>`, []string{"NP"}},
+		{"method returns array", `<Match><Method returns="byte[][]"/></Match>`, []string{"EI"}},
+		{"method params mismatch", `<Match><Method name="load" params="java.lang.String"/></Match>`, []string{}},
+		{"field", `<Match><Field name="~data|cache"/></Match>`, []string{"EI", "URF"}},
+		{"field array type", `<Match><Field type="byte[][]"/></Match>`, []string{"EI"}},
+		{"field nested type", `<Match><Field type="java.util.Map$Entry"/></Match>`, []string{"URF"}},
+		{"priority", `<Match><Priority value="2"/></Match>`, []string{"URF"}},
+		{"confidence", `<Match><Confidence value="1"/></Match>`, []string{"NP"}},
+		// A rank matches the bugs of that rank and the less scary ones
+		{"rank", `<Match><Rank value="18"/></Match>`, []string{"EI", "URF"}},
+		{"and", `<Match><And><Package name="com.example"/><Bug category="CORRECTNESS"/></And></Match>`, []string{"NP"}},
+		{"or", `<Match><Or><Bug code="NP"/><Bug code="URF"/></Or></Match>`, []string{"NP", "URF"}},
+		{"not", `<Match><Not><Package name="com.example"/></Not></Match>`, []string{"URF"}},
+		{"match clauses", `<Match><Package name="com.example"/><Priority value="3"/></Match>`, []string{"EI"}},
+		{"matches", `<Match><Bug code="NP"/></Match><Match><Bug code="EI"/></Match>`, []string{"NP", "EI"}},
+		{"empty", ``, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter := parseTestFilter(t, test.matches)
+			if got := matchingTypes(filter, testBugs); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// spotBugsManualFilter is the example filter of the SpotBugs manual, with its comments
+const spotBugsManualFilter = `<?xml version="1.0" encoding="UTF-8"?>
+<FindBugsFilter
+              xmlns="https://github.com/spotbugs/filter/3.0.0"
+              xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
+              xsi:schemaLocation="https://github.com/spotbugs/filter/3.0.0 https://raw.githubusercontent.com/spotbugs/spotbugs/3.1.0/spotbugs/etc/findbugsfilter.xsd">
+     <!-- Match all XYZ violations. -->
+     <Match>
+       <Bug code="XYZ" />
+     </Match>
+     <!-- Match all doublecheck violations in these methods of "AnotherClass". -->
+     <Match>
+       <Class name="com.foobar.AnotherClass" />
+       <Or>
+         <Method name="nonOverridableMethod" />
+         <Method name="shouldNeverBeCalled" />
+       </Or>
+       <Bug pattern="DC_DOUBLECHECK" />
+     </Match>
+     <!-- A method with an open stream false positive. -->
+     <Match>
+       <Class name="com.foobar.MyClass" />
+       <Method name="writeDataToFile" />
+       <Bug pattern="OBL_UNSATISFIED_OBLIGATION" />
+     </Match>
+     <!-- A method with a dead local store false positive (medium priority). -->
+     <Match>
+       <Class name="com.foobar.MyClass" />
+       <Method name="someMethod" />
+       <Bug pattern="DLS_DEAD_LOCAL_STORE" />
+       <Priority value="2" />
+     </Match>
+     <!-- All bugs in test classes, except for JUnit-specific bugs -->
+     <Match>
+      <Class name="~.*\.*Test" />
+      <Not>
+          <Bug code="IJU" />
+      </Not>
+     </Match>
+     <!-- Match method with parameters and return type -->
+     <Match>
+       <Class name="com.foobar.MyClass" />
+       <Method name="getData" params="int,java.lang.String[]" returns="byte[][]" />
+     </Match>
+</FindBugsFilter>`
+
+func TestSpotBugsManualFilter(t *testing.T) {
+	filter, err := ParseFilter(strings.NewReader(spotBugsManualFilter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		bug  BugInstance
+		want bool
+	}{
+		{"code", BugInstance{Abbrev: "XYZ"}, true},
+		{"or", BugInstance{Type: "DC_DOUBLECHECK", Class: Class{ClassName: "com.foobar.AnotherClass"},
+			Method: Method{Name: "shouldNeverBeCalled"}}, true},
+		{"or in another class", BugInstance{Type: "DC_DOUBLECHECK", Class: Class{ClassName: "com.foobar.MyClass"},
+			Method: Method{Name: "shouldNeverBeCalled"}}, false},
+		{"priority", BugInstance{Type: "DLS_DEAD_LOCAL_STORE", Priority: PriorityNormal,
+			Class: Class{ClassName: "com.foobar.MyClass"}, Method: Method{Name: "someMethod"}}, true},
+		{"other priority", BugInstance{Type: "DLS_DEAD_LOCAL_STORE", Priority: PriorityHigh,
+			Class: Class{ClassName: "com.foobar.MyClass"}, Method: Method{Name: "someMethod"}}, false},
+		{"test class", BugInstance{Abbrev: "NP", Class: Class{ClassName: "com.foobar.MyClassTest"}}, true},
+		{"JUnit bug in test class", BugInstance{Abbrev: "IJU", Class: Class{ClassName: "com.foobar.MyClassTest"}},
+			false},
+		{"signature", BugInstance{Abbrev: "EI", Class: Class{ClassName: "com.foobar.MyClass"},
+			Method: Method{Name: "getData", Signature: "(I[Ljava/lang/String;)[[B"}}, true},
+		{"other signature", BugInstance{Abbrev: "EI", Class: Class{ClassName: "com.foobar.MyClass"},
+			Method: Method{Name: "getData", Signature: "(ILjava/lang/String;)[[B"}}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := filter.Matches(test.bug); got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+	}{
+		{"not a filter", `<BugCollection/>`},
+		{"invalid XML", `<FindBugsFilter><Match>`},
+		{"element other than Match", `<FindBugsFilter><Bug code="NP"/></FindBugsFilter>`},
+		{"unknown element", `<FindBugsFilter><Match><Type name="NP"/></Match></FindBugsFilter>`},
+		{"not without children", `<FindBugsFilter><Match><Not/></Match></FindBugsFilter>`},
+		{"not with two children", `<FindBugsFilter><Match><Not><Bug code="NP"/><Bug code="EI"/></Not></Match></FindBugsFilter>`},
+		{"bug without attributes", `<FindBugsFilter><Match><Bug/></Match></FindBugsFilter>`},
+		{"class without name", `<FindBugsFilter><Match><Class/></Match></FindBugsFilter>`},
+		{"method without attributes", `<FindBugsFilter><Match><Method/></Match></FindBugsFilter>`},
+		{"invalid rank", `<FindBugsFilter><Match><Rank value="scary"/></Match></FindBugsFilter>`},
+		{"invalid regex", `<FindBugsFilter><Match><Class name="~com.(example"/></Match></FindBugsFilter>`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ParseFilter(strings.NewReader(test.filter)); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+	if _, err := ParseFilter(strings.NewReader(`<BugCollection/>`)); err != ErrNotFilter {
+		t.Errorf("got error %v, want ErrNotFilter", err)
+	}
+}
+
+func TestJavaTypes(t *testing.T) {
+	tests := []struct {
+		signature string
+		params    []string
+		result    string
+	}{
+		{"()V", []string{}, "void"},
+		{"(IJZ)D", []string{"int", "long", "boolean"}, "double"},
+		{"([Ljava/lang/String;)V", []string{"java.lang.String[]"}, "void"},
+		{"([[ILjava/util/Map$Entry;)[B", []string{"int[][]", "java.util.Map$Entry"}, "byte[]"},
+		{"Lcom/example/Foo$Builder;", []string{}, "com.example.Foo$Builder"},
+		{"[[C", []string{}, "char[][]"},
+	}
+	for _, test := range tests {
+		t.Run(test.signature, func(t *testing.T) {
+			params, result := javaTypes(test.signature)
+			if !reflect.DeepEqual(params, test.params) || result != test.result {
+				t.Errorf("got %v %s, want %v %s", params, result, test.params, test.result)
+			}
+		})
+	}
+}
+
+func TestApplyFilters(t *testing.T) {
+	bugCollection := BugCollection{
+		BugInstance: testBugs,
+		FindBugsSummary: FindBugsSummary{
+			TotalBugs:      3,
+			HighPriority:   1,
+			NormalPriority: 1,
+			LowPriority:    1,
+			PackageStats: []PackageStats{
+				{Package: "com.example", TotalBugs: 2, ClassStats: []ClassStats{
+					{Class: "com.example.Foo", Bugs: 1},
+					{Class: "com.example.Foo$Builder", Bugs: 1},
+				}},
+				{Package: "com.example.db", TotalBugs: 1, ClassStats: []ClassStats{
+					{Class: "com.example.db.Dao", Bugs: 1},
+				}},
+			},
+		},
+	}
+	include := []*Filter{parseTestFilter(t, `<Match><Package name="com.example"/></Match>`)}
+	exclude := []*Filter{parseTestFilter(t, `<Match><Class name="com.example.Foo$Builder"/></Match>`)}
+
+	filtered := ApplyFilters(bugCollection, include, exclude)
+	// An empty Match matches every bug
+	all := parseTestFilter(t, `<Match/>`)
+	if got := matchingTypes(all, filtered.BugInstance); !reflect.DeepEqual(got, []string{"NP"}) {
+		t.Errorf("got bugs %v, want only NP", got)
+	}
+	summary := filtered.FindBugsSummary
+	if summary.TotalBugs != 1 || summary.HighPriority != 1 || summary.NormalPriority != 0 || summary.LowPriority != 0 {
+		t.Errorf("got totals %+v, want only the bug of high priority", summary)
+	}
+	wantStats := []PackageStats{
+		{Package: "com.example", TotalBugs: 1, ClassStats: []ClassStats{
+			{Class: "com.example.Foo", Bugs: 1},
+			{Class: "com.example.Foo$Builder", Bugs: 0},
+		}},
+		{Package: "com.example.db", TotalBugs: 0, ClassStats: []ClassStats{
+			{Class: "com.example.db.Dao", Bugs: 0},
+		}},
+	}
+	if !reflect.DeepEqual(summary.PackageStats, wantStats) {
+		t.Errorf("got package stats %+v, want %+v", summary.PackageStats, wantStats)
+	}
+	// The original collection keeps its counts
+	if bugCollection.FindBugsSummary.PackageStats[0].TotalBugs != 2 ||
+		bugCollection.FindBugsSummary.PackageStats[0].ClassStats[1].Bugs != 1 {
+		t.Errorf("the package stats of the filtered collection were modified")
+	}
+
+	// Without filters every bug is kept
+	if unfiltered := ApplyFilters(bugCollection, nil, nil); len(unfiltered.BugInstance) != 3 {
+		t.Errorf("got %d bugs without filters, want 3", len(unfiltered.BugInstance))
+	}
+}
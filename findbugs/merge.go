@@ -0,0 +1,130 @@
+package findbugs
+
+// Merge combines the reports of several modules analysed separately into one report. Bugs, classpath entries and
+// errors are concatenated, counts and times summed, and statistics of packages and detectors found in several reports
+// combined. Attributes such as the version are taken from the first report which has them
+func Merge(collections ...BugCollection) BugCollection {
+	if len(collections) == 1 {
+		return collections[0]
+	}
+	var result BugCollection
+	packages := make(map[string]int)
+	profiles := make(map[string]int)
+	categories := make(map[string]bool)
+	patterns := make(map[string]bool)
+	codes := make(map[string]bool)
+	for _, c := range collections {
+		firstString(&result.Release, c.Release)
+		firstString(&result.AnalysisTimestamp, c.AnalysisTimestamp)
+		firstString(&result.Version, c.Version)
+		firstString(&result.Timestamp, c.Timestamp)
+		if c.Sequence > result.Sequence {
+			result.Sequence = c.Sequence
+		}
+
+		firstString(&result.Projects.ProjectName, c.Projects.ProjectName)
+		result.Projects.Jar = append(result.Projects.Jar, c.Projects.Jar...)
+		result.Projects.AuxClasspathEntry = append(result.Projects.AuxClasspathEntry, c.Projects.AuxClasspathEntry...)
+		result.Projects.SrcDir = append(result.Projects.SrcDir, c.Projects.SrcDir...)
+		firstString(&result.Projects.WrkDir, c.Projects.WrkDir)
+		mergeErrors(&result.Projects.Errors, c.Projects.Errors)
+		mergeErrors(&result.Errors, c.Errors)
+
+		mergeSummary(&result.FindBugsSummary, c.FindBugsSummary, packages, profiles)
+
+		result.BugInstance = append(result.BugInstance, c.BugInstance...)
+		for _, category := range c.BugCategory {
+			if !categories[category.Category] {
+				categories[category.Category] = true
+				result.BugCategory = append(result.BugCategory, category)
+			}
+		}
+		for _, pattern := range c.BugPattern {
+			if !patterns[pattern.Type] {
+				patterns[pattern.Type] = true
+				result.BugPattern = append(result.BugPattern, pattern)
+			}
+		}
+		for _, code := range c.BugCode {
+			if !codes[code.Abbrev] {
+				codes[code.Abbrev] = true
+				result.BugCode = append(result.BugCode, code)
+			}
+		}
+	}
+	return result
+}
+
+func mergeErrors(result *Errors, e Errors) {
+	result.MissingClasses += e.MissingClasses
+	result.Errors += e.Errors
+	result.MissingClass = append(result.MissingClass, e.MissingClass...)
+	result.AnalysisError = append(result.AnalysisError, e.AnalysisError...)
+}
+
+// mergeSummary adds s to result. packages and profiles index the PackageStats and ClassProfiles of result by name.
+// The modules are analysed one after the other, so times are summed while the peak memory is the highest of them
+func mergeSummary(result *FindBugsSummary, s FindBugsSummary, packages, profiles map[string]int) {
+	result.NumPackages += s.NumPackages
+	result.TotalClasses += s.TotalClasses
+	result.HighPriority += s.HighPriority
+	result.NormalPriority += s.NormalPriority
+	result.LowPriority += s.LowPriority
+	result.IgnorePriority += s.IgnorePriority
+	result.ExpPriority += s.ExpPriority
+	result.TotalSize += s.TotalSize
+	result.ReferencedClasses += s.ReferencedClasses
+	result.TotalBugs += s.TotalBugs
+	result.ClockSeconds += s.ClockSeconds
+	result.GCSeconds += s.GCSeconds
+	result.CPUSeconds += s.CPUSeconds
+	result.AllocMBytes += s.AllocMBytes
+	if s.PeakMBytes > result.PeakMBytes {
+		result.PeakMBytes = s.PeakMBytes
+	}
+	firstString(&result.VMVersion, s.VMVersion)
+	firstString(&result.JavaVersion, s.JavaVersion)
+	firstString(&result.Timestamp, s.Timestamp)
+
+	for _, stats := range s.PackageStats {
+		i, ok := packages[stats.Package]
+		if !ok {
+			packages[stats.Package] = len(result.PackageStats)
+			stats.ClassStats = append([]ClassStats(nil), stats.ClassStats...)
+			result.PackageStats = append(result.PackageStats, stats)
+			continue
+		}
+		merged := &result.PackageStats[i]
+		merged.TotalBugs += stats.TotalBugs
+		merged.TotalSize += stats.TotalSize
+		merged.TotalTypes += stats.TotalTypes
+		merged.ClassStats = append(merged.ClassStats, stats.ClassStats...)
+	}
+
+	for _, profile := range s.FindBugsProfile.ClassProfile {
+		i, ok := profiles[profile.Name]
+		if !ok {
+			profiles[profile.Name] = len(result.FindBugsProfile.ClassProfile)
+			result.FindBugsProfile.ClassProfile = append(result.FindBugsProfile.ClassProfile, profile)
+			continue
+		}
+		merged := &result.FindBugsProfile.ClassProfile[i]
+		merged.TotalMilliseconds += profile.TotalMilliseconds
+		merged.Invocations += profile.Invocations
+		if profile.MaxMicrosecondsPerInvocation > merged.MaxMicrosecondsPerInvocation {
+			merged.MaxMicrosecondsPerInvocation = profile.MaxMicrosecondsPerInvocation
+		}
+		if profile.StandardDeviationMicrosecondsPerInvocation > merged.StandardDeviationMicrosecondsPerInvocation {
+			merged.StandardDeviationMicrosecondsPerInvocation = profile.StandardDeviationMicrosecondsPerInvocation
+		}
+		if merged.Invocations > 0 {
+			merged.AvgMicrosecondsPerInvocation = merged.TotalMilliseconds * 1000 / merged.Invocations
+		}
+	}
+}
+
+func firstString(result *string, value string) {
+	if *result == "" {
+		*result = value
+	}
+}
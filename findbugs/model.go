@@ -30,9 +30,16 @@ type Project struct {
 }
 
 type Errors struct {
-	XMLName        xml.Name `xml:"Errors"`
-	MissingClasses int      `xml:"missingClasses,attr"`
-	Errors         int      `xml:"errors,attr"`
+	XMLName        xml.Name        `xml:"Errors"`
+	MissingClasses int             `xml:"missingClasses,attr"`
+	Errors         int             `xml:"errors,attr"`
+	MissingClass   []string        `xml:"MissingClass"`
+	AnalysisError  []AnalysisError `xml:"AnalysisError"`
+}
+
+type AnalysisError struct {
+	Message   string `xml:"message"`
+	Exception string `xml:"exception"`
 }
 
 type FindBugsSummary struct {
@@ -87,11 +94,11 @@ type FindBugsProfile struct {
 type ClassProfile struct {
 	XMLName                                    xml.Name `xml:"ClassProfile"`
 	AvgMicrosecondsPerInvocation               int      `xml:"avgMicrosecondsPerInvocation,attr"`
-	TotalMicrosecondsPerInvocation             int      `xml:"totalMicrosecondsPerInvocation,attr"`
+	MaxMicrosecondsPerInvocation               int      `xml:"maxMicrosecondsPerInvocation,attr"`
 	StandardDeviationMicrosecondsPerInvocation int      `xml:"standardDeviationMicrosecondsPerInvocation,attr"`
 	TotalMilliseconds                          int      `xml:"totalMilliseconds,attr"`
 	Invocations                                int      `xml:"invocations,attr"`
-	Name                                       string   `xml:"nameFile,attr"`
+	Name                                       string   `xml:"name,attr"`
 }
 
 type ClassFeatures struct {
@@ -123,57 +130,61 @@ type BugInstance struct {
 
 type Class struct {
 	XMLName    xml.Name   `xml:"Class"`
-	ClassName  string     `xml:"classname, attr"`
-	Primary    bool       `xml:"primary, attr"`
+	ClassName  string     `xml:"classname,attr"`
+	Primary    bool       `xml:"primary,attr"`
 	SourceLine SourceLine `xml:"SourceLine"`
 	Message    string     `xml:"Message"`
 }
 
 type SourceLine struct {
 	XMLName       xml.Name `xml:"SourceLine"`
-	ClassName     string   `xml:"classname, attr"`
-	SourcePath    string   `xml:"sourcepath, attr"`
-	SourceFile    string   `xml:"sourcefile, attr"`
-	Start         int      `xml:"start, attr"`
-	End           int      `xml:"end, attr"`
-	StartBytecode int      `xml:"startBytecode, attr"`
-	EndBytecode   int      `xml:"endBytecode, attr"`
+	ClassName     string   `xml:"classname,attr"`
+	SourcePath    string   `xml:"sourcepath,attr"`
+	SourceFile    string   `xml:"sourcefile,attr"`
+	Start         int      `xml:"start,attr"`
+	End           int      `xml:"end,attr"`
+	StartBytecode int      `xml:"startBytecode,attr"`
+	EndBytecode   int      `xml:"endBytecode,attr"`
+	Primary       bool     `xml:"primary,attr"`
+	Role          string   `xml:"role,attr"`
 	Message       string   `xml:"Message"`
 }
 
 type Method struct {
 	XMLName    xml.Name   `xml:"Method"`
-	IsStatic   bool       `xml:"isStatic, attr"`
-	ClassName  string     `xml:"classname, attr"`
-	Signature  string     `xml:"signature, attr"`
-	Name       string     `xml:"name, attr"`
-	Primary    bool       `xml:"primary, attr"`
+	IsStatic   bool       `xml:"isStatic,attr"`
+	ClassName  string     `xml:"classname,attr"`
+	Signature  string     `xml:"signature,attr"`
+	Name       string     `xml:"name,attr"`
+	Primary    bool       `xml:"primary,attr"`
 	SourceLine SourceLine `xml:"SourceLine"`
 	Message    string     `xml:"Message"`
 }
 
 type Field struct {
 	XMLName    xml.Name   `xml:"Field"`
-	IsStatic   bool       `xml:"isStatic, attr"`
-	ClassName  string     `xml:"classname, attr"`
-	Signature  string     `xml:"signature, attr"`
-	Name       string     `xml:"name, attr"`
-	Primary    bool       `xml:"primary, attr"`
+	IsStatic   bool       `xml:"isStatic,attr"`
+	ClassName  string     `xml:"classname,attr"`
+	Signature  string     `xml:"signature,attr"`
+	Name       string     `xml:"name,attr"`
+	Primary    bool       `xml:"primary,attr"`
 	SourceLine SourceLine `xml:"SourceLine"`
 	Message    string     `xml:"Message"`
 }
 
 type BugCategory struct {
-	XMLName  xml.Name `xml:"BugCategory"`
-	Category string   `xml:"category, attr"`
-	Message  string   `xml:"Message"`
+	XMLName     xml.Name `xml:"BugCategory"`
+	Category    string   `xml:"category,attr"`
+	Message     string   `xml:"Message"`
+	Description string   `xml:"Description"`
 }
 
 type BugPattern struct {
 	XMLName          xml.Name `xml:"BugPattern"`
-	Abbrev           string   `xml:"abbrev, attr"`
-	Type             string   `xml:"type, attr"`
-	Category         string   `xml:"category, attr"`
+	Abbrev           string   `xml:"abbrev,attr"`
+	Type             string   `xml:"type,attr"`
+	Category         string   `xml:"category,attr"`
+	Cweid            int      `xml:"cweid,attr"`
 	ShortDescription string   `xml:"ShortDescription"`
 	Details          string   `xml:"Details"`
 }
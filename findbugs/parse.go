@@ -0,0 +1,109 @@
+package findbugs
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotReport is returned by Parse if the XML document isn't a FindBugs or SpotBugs report
+var ErrNotReport = errors.New("not a FindBugs or SpotBugs report")
+
+// Parse decodes a FindBugs or SpotBugs XML report from r
+func Parse(r io.Reader) (collection BugCollection, err error) {
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return BugCollection{}, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local != "BugCollection" {
+			return BugCollection{}, ErrNotReport
+		}
+		err = decoder.DecodeElement(&collection, &start)
+		if err != nil {
+			return BugCollection{}, err
+		}
+		return collection, nil
+	}
+}
+
+// ParseFile decodes the FindBugs or SpotBugs XML report at path
+func ParseFile(path string) (BugCollection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return BugCollection{}, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// UnmarshalXML decodes a BugInstance. A bug instance may reference several classes, methods, fields and source lines,
+// the primary one of each is kept.
+func (b *BugInstance) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	// Bug has no methods, so decoding into it doesn't recurse into UnmarshalXML. It is embedded, so it must be
+	// exported for encoding/xml to set its fields
+	type Bug BugInstance
+	var raw struct {
+		Bug
+		Classes     []Class      `xml:"Class"`
+		Methods     []Method     `xml:"Method"`
+		Fields      []Field      `xml:"Field"`
+		SourceLines []SourceLine `xml:"SourceLine"`
+	}
+	err := d.DecodeElement(&raw, &start)
+	if err != nil {
+		return err
+	}
+	*b = BugInstance(raw.Bug)
+	for _, c := range raw.Classes {
+		if c.Primary || b.Class.ClassName == "" {
+			b.Class = c
+		}
+		if c.Primary {
+			break
+		}
+	}
+	for _, m := range raw.Methods {
+		if m.Primary || b.Method.Name == "" {
+			b.Method = m
+		}
+		if m.Primary {
+			break
+		}
+	}
+	for _, f := range raw.Fields {
+		if f.Primary || b.Field.Name == "" {
+			b.Field = f
+		}
+		if f.Primary {
+			break
+		}
+	}
+	b.SourceLine = primarySourceLine(raw.SourceLines)
+	return nil
+}
+
+// primarySourceLine picks the source line explicitly marked as primary, otherwise the first one without a role
+func primarySourceLine(lines []SourceLine) SourceLine {
+	for _, l := range lines {
+		if l.Primary {
+			return l
+		}
+	}
+	for _, l := range lines {
+		if l.Role == "" {
+			return l
+		}
+	}
+	if len(lines) > 0 {
+		return lines[0]
+	}
+	return SourceLine{}
+}
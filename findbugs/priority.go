@@ -0,0 +1,42 @@
+package findbugs
+
+import (
+	"strconv"
+	"strings"
+)
+
+// The confidence priorities SpotBugs assigns to a BugInstance
+const (
+	PriorityHigh         = 1
+	PriorityNormal       = 2
+	PriorityLow          = 3
+	PriorityExperimental = 4
+	PriorityIgnore       = 5
+)
+
+var priorityNames = map[int]string{
+	PriorityHigh:         "high",
+	PriorityNormal:       "normal",
+	PriorityLow:          "low",
+	PriorityExperimental: "experimental",
+	PriorityIgnore:       "ignored",
+}
+
+// PriorityName returns the lower case name of priority, e.g. high
+func PriorityName(priority int) string {
+	if name, ok := priorityNames[priority]; ok {
+		return name
+	}
+	return strconv.Itoa(priority)
+}
+
+// ParsePriority accepts either the name or the number of a priority
+func ParsePriority(value string) (int, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for priority, name := range priorityNames {
+		if name == value || strconv.Itoa(priority) == value {
+			return priority, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,53 @@
+package findbugs
+
+import (
+	"strconv"
+	"strings"
+)
+
+// The rank buckets SpotBugs groups the ranks 1 to 20 of a BugInstance in, from the scariest to the least scary
+const (
+	RankScariest  = "scariest"
+	RankScary     = "scary"
+	RankTroubling = "troubling"
+	RankConcern   = "concern"
+)
+
+// RankBuckets are the rank buckets, from the scariest to the least scary
+var RankBuckets = []string{RankScariest, RankScary, RankTroubling, RankConcern}
+
+// rankBucketMax is the highest, i.e. least scary, rank of each bucket
+var rankBucketMax = map[string]int{
+	RankScariest:  4,
+	RankScary:     9,
+	RankTroubling: 14,
+	RankConcern:   20,
+}
+
+// RankBucket returns the bucket rank belongs to, or an empty string if rank isn't between 1 and 20, e.g. as reports of
+// old FindBugs versions don't rank bugs
+func RankBucket(rank int) string {
+	if rank < 1 {
+		return ""
+	}
+	for _, bucket := range RankBuckets {
+		if rank <= rankBucketMax[bucket] {
+			return bucket
+		}
+	}
+	return ""
+}
+
+// ParseRank accepts either a rank between 1 and 20 or the name of a rank bucket, returning the highest rank of the
+// bucket
+func ParseRank(value string) (int, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if rank, ok := rankBucketMax[value]; ok {
+		return rank, true
+	}
+	rank, err := strconv.Atoi(value)
+	if err != nil || rank < 1 || rank > 20 {
+		return 0, false
+	}
+	return rank, true
+}
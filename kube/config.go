@@ -0,0 +1,274 @@
+package kube
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// RecommendedConfigPathEnvVar is the environment variable holding a list of kubeconfig files
+	RecommendedConfigPathEnvVar = "KUBECONFIG"
+	// RecommendedHomeDir is the directory below the home directory holding the default kubeconfig
+	RecommendedHomeDir = ".kube"
+	// RecommendedFileName is the name of the default kubeconfig file
+	RecommendedFileName = "config"
+)
+
+// LoadConfig returns the rest.Config used to connect to the cluster, following the same loading rules as kubectl:
+//
+// 1. the kubeconfig file passed explicitly, which must exist
+// 2. the files listed in $KUBECONFIG, merged so that the first file to set a value wins
+// 3. ~/.kube/config
+//
+// If none of these exist the in-cluster configuration is used. context selects a context other than the current
+// context of the kubeconfig. The namespace of the context is returned along with the configuration, it is empty for
+// the in-cluster configuration and contexts without a namespace.
+func LoadConfig(kubeconfig string, context string) (*rest.Config, string, error) {
+	var paths []string
+	switch {
+	case kubeconfig != "":
+		if _, err := os.Stat(kubeconfig); err != nil {
+			return nil, "", errors.Wrapf(err, "unable to read kubeconfig %s", kubeconfig)
+		}
+		paths = []string{kubeconfig}
+	case os.Getenv(RecommendedConfigPathEnvVar) != "":
+		paths = filepath.SplitList(os.Getenv(RecommendedConfigPathEnvVar))
+	default:
+		if home := homeDir(); home != "" {
+			paths = []string{filepath.Join(home, RecommendedHomeDir, RecommendedFileName)}
+		}
+	}
+
+	config := &kubeConfig{}
+	loaded := false
+	for _, path := range paths {
+		file, err := loadFile(path)
+		if os.IsNotExist(errors.Cause(err)) {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		config.merge(file)
+		loaded = true
+	}
+	if !loaded {
+		if context != "" {
+			return nil, "", errors.Errorf("context %s requested but no kubeconfig found", context)
+		}
+		config, err := inClusterConfig()
+		return config, "", err
+	}
+	if context == "" {
+		context = config.CurrentContext
+	}
+	return config.restConfig(context)
+}
+
+// inClusterConfig creates the configuration used when running in a pod, replaced by tests
+var inClusterConfig = rest.InClusterConfig
+
+// kubeConfig is the subset of the v1 kubeconfig file format needed to connect to a cluster
+type kubeConfig struct {
+	CurrentContext string         `json:"current-context"`
+	Clusters       []namedCluster `json:"clusters"`
+	AuthInfos      []namedUser    `json:"users"`
+	Contexts       []namedContext `json:"contexts"`
+}
+
+type namedCluster struct {
+	Name    string  `json:"name"`
+	Cluster cluster `json:"cluster"`
+}
+
+type cluster struct {
+	Server                   string `json:"server"`
+	InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify,omitempty"`
+	CertificateAuthority     string `json:"certificate-authority,omitempty"`
+	CertificateAuthorityData []byte `json:"certificate-authority-data,omitempty"`
+}
+
+type namedUser struct {
+	Name string `json:"name"`
+	User user   `json:"user"`
+}
+
+type user struct {
+	ClientCertificate     string                           `json:"client-certificate,omitempty"`
+	ClientCertificateData []byte                           `json:"client-certificate-data,omitempty"`
+	ClientKey             string                           `json:"client-key,omitempty"`
+	ClientKeyData         []byte                           `json:"client-key-data,omitempty"`
+	Token                 string                           `json:"token,omitempty"`
+	TokenFile             string                           `json:"tokenFile,omitempty"`
+	Username              string                           `json:"username,omitempty"`
+	Password              string                           `json:"password,omitempty"`
+	AuthProvider          *clientcmdapi.AuthProviderConfig `json:"auth-provider,omitempty"`
+	Exec                  *clientcmdapi.ExecConfig         `json:"exec,omitempty"`
+}
+
+type namedContext struct {
+	Name    string  `json:"name"`
+	Context context `json:"context"`
+}
+
+type context struct {
+	Cluster   string `json:"cluster"`
+	User      string `json:"user"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+func loadFile(path string) (*kubeConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read kubeconfig %s", path)
+	}
+	config := &kubeConfig{}
+	err = yaml.Unmarshal(data, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse kubeconfig %s", path)
+	}
+	// Relative paths are relative to the file they are defined in
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	for i := range config.Clusters {
+		cl := &config.Clusters[i].Cluster
+		cl.CertificateAuthority = resolve(dir, cl.CertificateAuthority)
+	}
+	for i := range config.AuthInfos {
+		u := &config.AuthInfos[i].User
+		u.ClientCertificate = resolve(dir, u.ClientCertificate)
+		u.ClientKey = resolve(dir, u.ClientKey)
+		u.TokenFile = resolve(dir, u.TokenFile)
+	}
+	return config, nil
+}
+
+// merge adds the entries of other which aren't already defined, so the first file to define an entry wins
+func (c *kubeConfig) merge(other *kubeConfig) {
+	if c.CurrentContext == "" {
+		c.CurrentContext = other.CurrentContext
+	}
+	for _, o := range other.Clusters {
+		if c.cluster(o.Name) == nil {
+			c.Clusters = append(c.Clusters, o)
+		}
+	}
+	for _, o := range other.AuthInfos {
+		if c.user(o.Name) == nil {
+			c.AuthInfos = append(c.AuthInfos, o)
+		}
+	}
+	for _, o := range other.Contexts {
+		if c.context(o.Name) == nil {
+			c.Contexts = append(c.Contexts, o)
+		}
+	}
+}
+
+func (c *kubeConfig) cluster(name string) *cluster {
+	for i := range c.Clusters {
+		if c.Clusters[i].Name == name {
+			return &c.Clusters[i].Cluster
+		}
+	}
+	return nil
+}
+
+func (c *kubeConfig) user(name string) *user {
+	for i := range c.AuthInfos {
+		if c.AuthInfos[i].Name == name {
+			return &c.AuthInfos[i].User
+		}
+	}
+	return nil
+}
+
+func (c *kubeConfig) context(name string) *context {
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == name {
+			return &c.Contexts[i].Context
+		}
+	}
+	return nil
+}
+
+// restConfig creates the rest.Config for the context called name, returning the namespace of the context along with
+// it. Users authenticating with an auth provider or exec plugin are rejected, as the plugins aren't built in
+func (c *kubeConfig) restConfig(name string) (*rest.Config, string, error) {
+	if name == "" {
+		return nil, "", errors.New("no context selected and the kubeconfig has no current-context")
+	}
+	ctx := c.context(name)
+	if ctx == nil {
+		return nil, "", errors.Errorf("context %s not found in kubeconfig", name)
+	}
+	cl := c.cluster(ctx.Cluster)
+	if cl == nil {
+		return nil, "", errors.Errorf("cluster %s of context %s not found in kubeconfig", ctx.Cluster, name)
+	}
+	if cl.Server == "" {
+		return nil, "", errors.Errorf("cluster %s has no server", ctx.Cluster)
+	}
+	config := &rest.Config{
+		Host: cl.Server,
+		TLSClientConfig: rest.TLSClientConfig{
+			Insecure: cl.InsecureSkipTLSVerify,
+			CAFile:   cl.CertificateAuthority,
+			CAData:   cl.CertificateAuthorityData,
+		},
+	}
+	if ctx.User == "" {
+		return config, ctx.Namespace, nil
+	}
+	u := c.user(ctx.User)
+	if u == nil {
+		return nil, "", errors.Errorf("user %s of context %s not found in kubeconfig", ctx.User, name)
+	}
+	if u.AuthProvider != nil {
+		return nil, "", errors.Errorf("user %s authenticates with the auth provider %s, which isn't supported; use a "+
+			"token or client certificate instead", ctx.User, u.AuthProvider.Name)
+	}
+	if u.Exec != nil {
+		return nil, "", errors.Errorf("user %s authenticates with the exec plugin %s, which isn't supported; use a "+
+			"token or client certificate instead", ctx.User, u.Exec.Command)
+	}
+	config.CertFile = u.ClientCertificate
+	config.CertData = u.ClientCertificateData
+	config.KeyFile = u.ClientKey
+	config.KeyData = u.ClientKeyData
+	config.BearerToken = u.Token
+	if u.TokenFile != "" && u.Token == "" {
+		token, err := ioutil.ReadFile(u.TokenFile)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "unable to read token file of user %s", ctx.User)
+		}
+		config.BearerToken = strings.TrimSpace(string(token))
+	}
+	config.Username = u.Username
+	config.Password = u.Password
+	return config, ctx.Namespace, nil
+}
+
+// resolve makes path absolute relative to dir
+func resolve(dir string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+func homeDir() string {
+	if home := os.Getenv("HOME"); home != "" {
+		return home
+	}
+	return os.Getenv("USERPROFILE")
+}
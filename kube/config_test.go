@@ -0,0 +1,205 @@
+package kube
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+const testKubeconfig = `
+apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com
+    certificate-authority: ca.crt
+- name: prod
+  cluster:
+    server: https://prod.example.com
+    insecure-skip-tls-verify: true
+users:
+- name: token
+  user:
+    token: secret
+- name: token-file
+  user:
+    tokenFile: token
+- name: client-cert
+  user:
+    client-certificate: client.crt
+    client-key: /keys/client.key
+- name: gcp
+  user:
+    auth-provider:
+      name: gcp
+- name: aws
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: aws
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: token
+    namespace: jx
+- name: prod
+  context:
+    cluster: prod
+    user: client-cert
+- name: token-file
+  context:
+    cluster: dev
+    user: token-file
+- name: anonymous
+  context:
+    cluster: prod
+- name: gcp
+  context:
+    cluster: prod
+    user: gcp
+- name: aws
+  context:
+    cluster: prod
+    user: aws
+- name: missing-user
+  context:
+    cluster: prod
+    user: missing
+`
+
+func writeKubeconfig(t *testing.T) string {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(path, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "token"), []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeKubeconfig(t)
+	dir := filepath.Dir(path)
+	tests := []struct {
+		context   string
+		want      rest.Config
+		namespace string
+		err       string
+	}{
+		{
+			context: "",
+			want: rest.Config{
+				Host:            "https://dev.example.com",
+				BearerToken:     "secret",
+				TLSClientConfig: rest.TLSClientConfig{CAFile: filepath.Join(dir, "ca.crt")},
+			},
+			namespace: "jx",
+		},
+		{
+			context: "prod",
+			want: rest.Config{
+				Host: "https://prod.example.com",
+				TLSClientConfig: rest.TLSClientConfig{
+					Insecure: true,
+					CertFile: filepath.Join(dir, "client.crt"),
+					KeyFile:  "/keys/client.key",
+				},
+			},
+		},
+		{
+			context: "token-file",
+			want: rest.Config{
+				Host:            "https://dev.example.com",
+				BearerToken:     "from-file",
+				TLSClientConfig: rest.TLSClientConfig{CAFile: filepath.Join(dir, "ca.crt")},
+			},
+		},
+		{
+			context: "anonymous",
+			want: rest.Config{
+				Host:            "https://prod.example.com",
+				TLSClientConfig: rest.TLSClientConfig{Insecure: true},
+			},
+		},
+		{context: "gcp", err: "auth provider gcp, which isn't supported"},
+		{context: "aws", err: "exec plugin aws, which isn't supported"},
+		{context: "missing-user", err: "user missing of context missing-user not found"},
+		{context: "missing", err: "context missing not found"},
+	}
+	for _, test := range tests {
+		t.Run(test.context, func(t *testing.T) {
+			config, namespace, err := LoadConfig(path, test.context)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Host != test.want.Host || config.BearerToken != test.want.BearerToken ||
+				config.TLSClientConfig.Insecure != test.want.TLSClientConfig.Insecure ||
+				config.CAFile != test.want.CAFile || config.CertFile != test.want.CertFile ||
+				config.KeyFile != test.want.KeyFile {
+				t.Errorf("got config %+v, want %+v", config, test.want)
+			}
+			if namespace != test.namespace {
+				t.Errorf("got namespace %q, want %q", namespace, test.namespace)
+			}
+		})
+	}
+}
+
+func TestLoadConfigMergesKubeconfigEnvVar(t *testing.T) {
+	path := writeKubeconfig(t)
+	// The first file to set a value wins, so its current context is used
+	first := filepath.Join(t.TempDir(), "config")
+	if err := ioutil.WriteFile(first, []byte("current-context: prod\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(t.TempDir(), "missing")
+	t.Setenv(RecommendedConfigPathEnvVar, strings.Join([]string{missing, first, path}, string(os.PathListSeparator)))
+	config, _, err := LoadConfig("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "https://prod.example.com" {
+		t.Errorf("got host %s, want the cluster of the current context of the first file", config.Host)
+	}
+}
+
+func TestLoadConfigFallsBackToInCluster(t *testing.T) {
+	inCluster := &rest.Config{Host: "https://10.0.0.1:443"}
+	defer func(previous func() (*rest.Config, error)) {
+		inClusterConfig = previous
+	}(inClusterConfig)
+	inClusterConfig = func() (*rest.Config, error) {
+		return inCluster, nil
+	}
+	t.Setenv(RecommendedConfigPathEnvVar, "")
+	t.Setenv("HOME", t.TempDir())
+
+	config, namespace, err := LoadConfig("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config != inCluster || namespace != "" {
+		t.Errorf("got config %+v in namespace %q, want the in-cluster config", config, namespace)
+	}
+	if _, _, err := LoadConfig("", "dev"); err == nil {
+		t.Error("got no error for a context without a kubeconfig")
+	}
+	if _, _, err := LoadConfig(filepath.Join(t.TempDir(), "missing"), ""); err == nil {
+		t.Error("got no error for a missing kubeconfig passed explicitly")
+	}
+}
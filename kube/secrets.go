@@ -0,0 +1,49 @@
+package kube
+
+import (
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/rest"
+
+	"github.com/jenkins-x/jx/pkg/client/clientset/versioned/scheme"
+)
+
+// SecretClient reads Secrets. client-go doesn't ship the typed core clients at the version we vendor, so this only
+// implements what the analyzer needs
+type SecretClient struct {
+	client rest.Interface
+}
+
+// NewSecretClient creates a SecretClient from config
+func NewSecretClient(c *rest.Config) (*SecretClient, error) {
+	config := *c
+	config.GroupVersion = &schema.GroupVersion{Version: "v1"}
+	config.APIPath = "/api"
+	config.ContentType = "application/json"
+	config.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: scheme.Codecs}
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+	client, err := rest.RESTClientFor(&config)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretClient{client: client}, nil
+}
+
+// Get returns the Secret called name in namespace
+func (c *SecretClient) Get(namespace, name string) (*corev1.Secret, error) {
+	body, err := c.client.Get().Namespace(namespace).Resource("secrets").Name(name).Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{}
+	err = json.Unmarshal(body, secret)
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
@@ -0,0 +1,200 @@
+package leader
+
+import (
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// DefaultLeaseDuration is how long non-leaders wait before trying to take over an unrenewed lease
+	DefaultLeaseDuration = 15 * time.Second
+	// DefaultRenewDeadline is how long the leader keeps retrying to renew the lease before giving up leadership
+	DefaultRenewDeadline = 10 * time.Second
+	// DefaultRetryPeriod is how long to wait between attempts to acquire or renew the lease
+	DefaultRetryPeriod = 2 * time.Second
+)
+
+// Config configures an Elector
+type Config struct {
+	// Leases is the client used to read and write the lease
+	Leases *LeaseClient
+	// Name is the name of the Lease
+	Name string
+	// Identity uniquely identifies this candidate, usually the pod name
+	Identity string
+
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+
+	// OnStartedLeading is called once the lease is acquired. stop is closed when leadership is lost
+	OnStartedLeading func(stop <-chan struct{})
+	// OnStoppedLeading is called when leadership is lost, or when Run returns before it was acquired
+	OnStoppedLeading func()
+}
+
+// Elector uses a Lease to elect a single leader among a set of candidates. The remaining candidates stay on hot
+// standby, retrying to acquire the lease should the leader fail to renew it
+type Elector struct {
+	config Config
+
+	observedSpec LeaseSpec
+	observedTime time.Time
+}
+
+// NewElector validates config, applies defaults and returns an Elector
+func NewElector(config Config) (*Elector, error) {
+	if config.Leases == nil || config.Name == "" || config.Identity == "" {
+		return nil, errors.New("leader election requires a lease client, a lease name and an identity")
+	}
+	if config.LeaseDuration == 0 {
+		config.LeaseDuration = DefaultLeaseDuration
+	}
+	if config.RenewDeadline == 0 {
+		config.RenewDeadline = DefaultRenewDeadline
+	}
+	if config.RetryPeriod == 0 {
+		config.RetryPeriod = DefaultRetryPeriod
+	}
+	if config.LeaseDuration <= config.RenewDeadline || config.RenewDeadline <= config.RetryPeriod {
+		return nil, errors.New("leader election requires leaseDuration > renewDeadline > retryPeriod")
+	}
+	return &Elector{config: config}, nil
+}
+
+// Run blocks until the lease is acquired, calls OnStartedLeading and keeps renewing the lease until renewal fails or
+// stop is closed. OnStoppedLeading is always called before Run returns
+func (e *Elector) Run(stop <-chan struct{}) {
+	defer func() {
+		if e.config.OnStoppedLeading != nil {
+			e.config.OnStoppedLeading()
+		}
+	}()
+	if !e.acquire(stop) {
+		return
+	}
+	leading := make(chan struct{})
+	defer close(leading)
+	if e.config.OnStartedLeading != nil {
+		go e.config.OnStartedLeading(leading)
+	}
+	e.renew(stop)
+}
+
+// acquire retries to acquire the lease every RetryPeriod, returning false if stop is closed first
+func (e *Elector) acquire(stop <-chan struct{}) bool {
+	acquired := false
+	done := make(chan struct{})
+	log.Printf("Attempting to acquire lease %s as %s\n", e.config.Name, e.config.Identity)
+	wait.JitterUntil(func() {
+		acquired = e.tryAcquireOrRenew()
+		if acquired {
+			log.Printf("Acquired lease %s as %s\n", e.config.Name, e.config.Identity)
+			close(done)
+		}
+	}, e.config.RetryPeriod, 1.2, true, mergeStop(stop, done))
+	return acquired
+}
+
+// renew renews the lease every RetryPeriod until a renewal does not succeed within RenewDeadline or stop is closed
+func (e *Elector) renew(stop <-chan struct{}) {
+	done := make(chan struct{})
+	wait.Until(func() {
+		err := wait.Poll(e.config.RetryPeriod/2, e.config.RenewDeadline, func() (bool, error) {
+			return e.tryAcquireOrRenew(), nil
+		})
+		if err != nil {
+			log.Printf("Failed to renew lease %s as %s: %v\n", e.config.Name, e.config.Identity, err)
+			close(done)
+		}
+	}, e.config.RetryPeriod, mergeStop(stop, done))
+}
+
+// tryAcquireOrRenew creates or updates the lease if it is free, expired or already held by us
+func (e *Elector) tryAcquireOrRenew() bool {
+	now := metav1.NowMicro()
+	durationSeconds := int32(e.config.LeaseDuration / time.Second)
+	lease, err := e.config.Leases.Get(e.config.Name)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Printf("Error retrieving lease %s: %v\n", e.config.Name, err)
+			return false
+		}
+		transitions := int32(0)
+		lease = &Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: e.config.Name},
+			Spec: LeaseSpec{
+				HolderIdentity:       &e.config.Identity,
+				LeaseDurationSeconds: &durationSeconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+				LeaseTransitions:     &transitions,
+			},
+		}
+		lease, err = e.config.Leases.Create(lease)
+		if err != nil {
+			log.Printf("Error creating lease %s: %v\n", e.config.Name, err)
+			return false
+		}
+		e.observe(lease.Spec)
+		return true
+	}
+
+	// Expiry is judged against our own clock from when we last saw the lease change, so clock skew between
+	// candidates doesn't matter
+	if !reflect.DeepEqual(e.observedSpec, lease.Spec) {
+		e.observe(lease.Spec)
+	}
+	holder := ""
+	if lease.Spec.HolderIdentity != nil {
+		holder = *lease.Spec.HolderIdentity
+	}
+	if holder != "" && holder != e.config.Identity && e.observedTime.Add(e.config.LeaseDuration).After(time.Now()) {
+		return false
+	}
+
+	if holder != e.config.Identity {
+		transitions := int32(1)
+		if lease.Spec.LeaseTransitions != nil {
+			transitions = *lease.Spec.LeaseTransitions + 1
+		}
+		lease.Spec.LeaseTransitions = &transitions
+		lease.Spec.AcquireTime = &now
+	}
+	lease.Spec.HolderIdentity = &e.config.Identity
+	lease.Spec.LeaseDurationSeconds = &durationSeconds
+	lease.Spec.RenewTime = &now
+	lease, err = e.config.Leases.Update(lease)
+	if err != nil {
+		if !apierrors.IsConflict(err) {
+			log.Printf("Error updating lease %s: %v\n", e.config.Name, err)
+		}
+		return false
+	}
+	e.observe(lease.Spec)
+	return true
+}
+
+func (e *Elector) observe(spec LeaseSpec) {
+	e.observedSpec = spec
+	e.observedTime = time.Now()
+}
+
+// mergeStop returns a channel which is closed as soon as either a or b is closed
+func mergeStop(a, b <-chan struct{}) <-chan struct{} {
+	merged := make(chan struct{})
+	go func() {
+		defer close(merged)
+		select {
+		case <-a:
+		case <-b:
+		}
+	}()
+	return merged
+}
@@ -0,0 +1,277 @@
+package leader
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// fakeLeases serves Leases the way the API server does, rejecting updates of a stale resourceVersion with a conflict
+type fakeLeases struct {
+	mu     sync.Mutex
+	leases map[string]*Lease
+	// failing makes every request fail, as when the API server is unreachable
+	failing bool
+}
+
+const leasesAPIPath = "/apis/coordination.k8s.io/v1/namespaces/jx/leases"
+
+func newFakeLeases(t *testing.T) (*fakeLeases, *LeaseClient) {
+	f := &fakeLeases{leases: make(map[string]*Lease)}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	client, err := NewLeaseClient(&rest.Config{Host: server.URL}, "jx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, client
+}
+
+func (f *fakeLeases) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing {
+		writeStatus(w, http.StatusInternalServerError, "InternalError")
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, leasesAPIPath) {
+		http.NotFound(w, r)
+		return
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, leasesAPIPath), "/")
+	var lease *Lease
+	switch r.Method {
+	case http.MethodGet:
+		lease = f.leases[name]
+		if lease == nil {
+			writeStatus(w, http.StatusNotFound, "NotFound")
+			return
+		}
+	case http.MethodPost, http.MethodPut:
+		lease = &Lease{}
+		if err := json.NewDecoder(r.Body).Decode(lease); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		current := f.leases[lease.Name]
+		switch {
+		case r.Method == http.MethodPost && current != nil:
+			writeStatus(w, http.StatusConflict, "AlreadyExists")
+			return
+		case r.Method == http.MethodPut && current == nil:
+			writeStatus(w, http.StatusNotFound, "NotFound")
+			return
+		case r.Method == http.MethodPut && current.ResourceVersion != lease.ResourceVersion:
+			writeStatus(w, http.StatusConflict, "Conflict")
+			return
+		}
+		version := 0
+		if current != nil {
+			version, _ = strconv.Atoi(current.ResourceVersion)
+		}
+		lease.ResourceVersion = strconv.Itoa(version + 1)
+		f.leases[lease.Name] = lease
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lease)
+}
+
+func (f *fakeLeases) holder(name string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	lease := f.leases[name]
+	if lease == nil || lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+func (f *fakeLeases) setFailing(failing bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing = failing
+}
+
+func writeStatus(w http.ResponseWriter, code int, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"kind":       "Status",
+		"apiVersion": "v1",
+		"status":     "Failure",
+		"reason":     reason,
+		"code":       code,
+	})
+}
+
+func TestNewElector(t *testing.T) {
+	leases := &LeaseClient{}
+	tests := []struct {
+		name   string
+		config Config
+		valid  bool
+	}{
+		{"defaults", Config{Leases: leases, Name: "lease", Identity: "a"}, true},
+		{"no lease client", Config{Name: "lease", Identity: "a"}, false},
+		{"no name", Config{Leases: leases, Identity: "a"}, false},
+		{"no identity", Config{Leases: leases, Name: "lease"}, false},
+		{"renew deadline not shorter than the lease", Config{Leases: leases, Name: "lease", Identity: "a",
+			LeaseDuration: time.Second, RenewDeadline: time.Second}, false},
+		{"retry period not shorter than the renew deadline", Config{Leases: leases, Name: "lease", Identity: "a",
+			RetryPeriod: DefaultRenewDeadline}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			elector, err := NewElector(test.config)
+			if (err == nil) != test.valid {
+				t.Fatalf("got error %v, want valid %t", err, test.valid)
+			}
+			if test.valid && elector.config.LeaseDuration != DefaultLeaseDuration {
+				t.Errorf("got lease duration %s, want the default", elector.config.LeaseDuration)
+			}
+		})
+	}
+}
+
+func TestTryAcquireOrRenew(t *testing.T) {
+	leases, client := newFakeLeases(t)
+	newCandidate := func(identity string) *Elector {
+		elector, err := NewElector(Config{Leases: client, Name: "lease", Identity: identity})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return elector
+	}
+	a, b := newCandidate("a"), newCandidate("b")
+
+	if !a.tryAcquireOrRenew() {
+		t.Fatal("a didn't acquire the free lease")
+	}
+	if b.tryAcquireOrRenew() {
+		t.Fatal("b acquired the lease held by a")
+	}
+	if !a.tryAcquireOrRenew() {
+		t.Fatal("a didn't renew its lease")
+	}
+	if got := leases.holder("lease"); got != "a" {
+		t.Fatalf("got holder %q, want a", got)
+	}
+
+	// b last saw the lease change longer than the lease duration ago, so a failed to renew it
+	b.tryAcquireOrRenew()
+	b.observedTime = b.observedTime.Add(-DefaultLeaseDuration - time.Second)
+	if !b.tryAcquireOrRenew() {
+		t.Fatal("b didn't take over the expired lease")
+	}
+	lease, err := client.Get("lease")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *lease.Spec.HolderIdentity != "b" || *lease.Spec.LeaseTransitions != 1 {
+		t.Errorf("got holder %s after %d transitions, want b after 1", *lease.Spec.HolderIdentity,
+			*lease.Spec.LeaseTransitions)
+	}
+	if a.tryAcquireOrRenew() {
+		t.Error("a renewed the lease taken over by b")
+	}
+}
+
+func TestTryAcquireOrRenewFails(t *testing.T) {
+	leases, client := newFakeLeases(t)
+	elector, err := NewElector(Config{Leases: client, Name: "lease", Identity: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leases.setFailing(true)
+	if elector.tryAcquireOrRenew() {
+		t.Error("acquired the lease without reaching the API server")
+	}
+}
+
+func TestRun(t *testing.T) {
+	leases, client := newFakeLeases(t)
+	started := make(chan (<-chan struct{}), 1)
+	stopped := make(chan struct{})
+	elector, err := NewElector(Config{
+		Leases:        client,
+		Name:          "lease",
+		Identity:      "a",
+		LeaseDuration: 400 * time.Millisecond,
+		RenewDeadline: 200 * time.Millisecond,
+		RetryPeriod:   20 * time.Millisecond,
+		OnStartedLeading: func(stop <-chan struct{}) {
+			started <- stop
+		},
+		OnStoppedLeading: func() {
+			close(stopped)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go elector.Run(nil)
+
+	var leading <-chan struct{}
+	select {
+	case leading = <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("didn't start leading")
+	}
+	if got := leases.holder("lease"); got != "a" {
+		t.Errorf("got holder %q, want a", got)
+	}
+
+	// Leadership is given up once the lease can't be renewed within the renew deadline
+	leases.setFailing(true)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("didn't stop leading")
+	}
+	select {
+	case <-leading:
+	case <-time.After(5 * time.Second):
+		t.Error("the stop channel passed to OnStartedLeading wasn't closed")
+	}
+}
+
+func TestRunStopped(t *testing.T) {
+	leases, client := newFakeLeases(t)
+	leases.setFailing(true)
+	stopped := make(chan struct{})
+	elector, err := NewElector(Config{
+		Leases:        client,
+		Name:          "lease",
+		Identity:      "a",
+		LeaseDuration: 400 * time.Millisecond,
+		RenewDeadline: 200 * time.Millisecond,
+		RetryPeriod:   20 * time.Millisecond,
+		OnStartedLeading: func(stop <-chan struct{}) {
+			t.Error("started leading without the lease")
+		},
+		OnStoppedLeading: func() {
+			close(stopped)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	go elector.Run(stop)
+	close(stop)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return once stopped")
+	}
+}
@@ -0,0 +1,105 @@
+package leader
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/rest"
+
+	"github.com/jenkins-x/jx/pkg/client/clientset/versioned/scheme"
+)
+
+// LeaseGroupVersion is the API group version of the Lease resource used for locking
+var LeaseGroupVersion = schema.GroupVersion{Group: "coordination.k8s.io", Version: "v1"}
+
+// Lease is a coordination.k8s.io/v1 Lease. client-go doesn't ship a typed client for Leases at the version we use, so
+// we only model the fields needed for leader election
+type Lease struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              LeaseSpec `json:"spec,omitempty"`
+}
+
+// LeaseSpec is the specification of a Lease
+type LeaseSpec struct {
+	HolderIdentity       *string           `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds *int32            `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          *metav1.MicroTime `json:"acquireTime,omitempty"`
+	RenewTime            *metav1.MicroTime `json:"renewTime,omitempty"`
+	LeaseTransitions     *int32            `json:"leaseTransitions,omitempty"`
+}
+
+// LeaseClient reads and writes Leases in a single namespace
+type LeaseClient struct {
+	client    rest.Interface
+	namespace string
+}
+
+// NewLeaseClient creates a LeaseClient for namespace from config
+func NewLeaseClient(c *rest.Config, namespace string) (*LeaseClient, error) {
+	config := *c
+	config.GroupVersion = &LeaseGroupVersion
+	config.APIPath = "/apis"
+	config.ContentType = "application/json"
+	config.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: scheme.Codecs}
+	if config.UserAgent == "" {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+	client, err := rest.RESTClientFor(&config)
+	if err != nil {
+		return nil, err
+	}
+	return &LeaseClient{client: client, namespace: namespace}, nil
+}
+
+// Get returns the Lease called name
+func (c *LeaseClient) Get(name string) (*Lease, error) {
+	body, err := c.client.Get().Namespace(c.namespace).Resource("leases").Name(name).Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+	return decodeLease(body)
+}
+
+// Create creates lease
+func (c *LeaseClient) Create(lease *Lease) (*Lease, error) {
+	data, err := encodeLease(lease)
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.client.Post().Namespace(c.namespace).Resource("leases").Body(data).Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+	return decodeLease(body)
+}
+
+// Update replaces lease, failing with a conflict if it has been changed since it was read
+func (c *LeaseClient) Update(lease *Lease) (*Lease, error) {
+	data, err := encodeLease(lease)
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.client.Put().Namespace(c.namespace).Resource("leases").Name(lease.Name).Body(data).Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+	return decodeLease(body)
+}
+
+func encodeLease(lease *Lease) ([]byte, error) {
+	lease.APIVersion = LeaseGroupVersion.String()
+	lease.Kind = "Lease"
+	return json.Marshal(lease)
+}
+
+func decodeLease(data []byte) (*Lease, error) {
+	lease := &Lease{}
+	err := json.Unmarshal(data, lease)
+	if err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
@@ -1,126 +1,469 @@
 package main
 
 import (
-	"encoding/xml"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	jenkinsv1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
 	jenkinsclientv1 "github.com/jenkins-x/jx/pkg/client/clientset/versioned/typed/jenkins.io/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/rest"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	k8swatch "k8s.io/apimachinery/pkg/watch"
 
+	"github.com/jenkins-x/ext-spotbugs/fetch"
 	"github.com/jenkins-x/ext-spotbugs/findbugs"
+	"github.com/jenkins-x/ext-spotbugs/kube"
 
 	"github.com/pkg/errors"
 )
 
-func watch() (err error) {
-	// creates the in-cluster config
-	config, err := rest.InClusterConfig()
+// analyzer summarises the SpotBugs reports attached to PipelineActivities
+type analyzer struct {
+	client  jenkinsclientv1.JenkinsV1Interface
+	fetcher fetch.ReportFetcher
+	// maxSize limits the size of the reports fetched from an activity once decompressed, 0 for no limit
+	maxSize int64
+	// publicURL is the external URL of the API, summaries link to the HTML report served there. Empty if the API
+	// isn't exposed
+	publicURL string
+	config    analysisConfig
+	filters   filterConfig
+	costs     *costHistory
+}
+
+func newAnalyzer(client jenkinsclientv1.JenkinsV1Interface, fetcher fetch.ReportFetcher, maxSize int64,
+	publicURL string, config analysisConfig, filters filterConfig) *analyzer {
+	return &analyzer{
+		client:    client,
+		fetcher:   fetcher,
+		maxSize:   maxSize,
+		config:    config,
+		filters:   filters,
+		costs:     newCostHistory(),
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+	}
+}
+
+// watchRestartDelay is how long to wait before watching PipelineActivities again once a watch has ended
+const watchRestartDelay = time.Second
+
+// run watches PipelineActivities until stop is closed. The API server ends watches after a while, so a new watch is
+// started whenever one ends. A new watch lists the existing activities again, which are skipped if already summarised.
+// The events of every watch are processed by the same queue, so a new watch doesn't wait for the reports still being
+// fetched; run waits for them once stopped
+func (a *analyzer) run(config watchConfig, stop <-chan struct{}) error {
+	queue := newWorkQueue(config.Workers, a.process)
+	defer queue.shutDown()
+	for {
+		err := a.watch(config, queue, stop)
+		if err != nil {
+			return err
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(watchRestartDelay):
+			log.Println("Watch of PipelineActivities ended, watching again")
+		}
+	}
+}
+
+// watch adds the activities of the events of a single watch of PipelineActivities to queue, returning once it ends,
+// the API server sends an error, e.g. because the watched resourceVersion is too old, or stop is closed
+func (a *analyzer) watch(config watchConfig, queue *workQueue, stop <-chan struct{}) (err error) {
+	ended := make(chan struct{})
+	defer close(ended)
+	events, err := watchActivities(a.client, config, mergeStop(stop, ended))
 	if err != nil {
 		return err
 	}
-	ns := os.Getenv("SPOTBUGS_NAMESPACE")
-	client, err := jenkinsclientv1.NewForConfig(config)
+	// The watches of the other namespaces are stopped once this one has ended, their remaining events are dropped
+	defer func() {
+		go func() {
+			for range events {
+			}
+		}()
+	}()
+
+	for event := range events {
+		switch event.Type {
+		case k8swatch.Error:
+			log.Printf("Error watching PipelineActivities: %v\n", apierrors.FromObject(event.Object))
+			return nil
+		case k8swatch.Deleted:
+			continue
+		}
+		act, ok := event.Object.(*jenkinsv1.PipelineActivity)
+		if !ok {
+			log.Printf("Unexpected %s event for a %T while watching PipelineActivities\n", event.Type, event.Object)
+			continue
+		}
+		queue.add(act)
+	}
+	return nil
+}
+
+// mergeStop returns a channel which is closed as soon as either a or b is closed
+func mergeStop(a, b <-chan struct{}) <-chan struct{} {
+	merged := make(chan struct{})
+	go func() {
+		defer close(merged)
+		select {
+		case <-a:
+		case <-b:
+		}
+	}()
+	return merged
+}
+
+// backfill summarises the PipelineActivities which already exist once, rather than watching for changes
+func (a *analyzer) backfill(config watchConfig) error {
+	queue := newWorkQueue(config.Workers, a.process)
+	defer queue.shutDown()
+	for _, ns := range config.watchNamespaces() {
+		list, err := a.client.PipelineActivities(ns).List(config.listOptions())
+		if err != nil {
+			return errors.Wrapf(err, "unable to list PipelineActivities in namespace %q", ns)
+		}
+		for i := range list.Items {
+			queue.add(&list.Items[i])
+		}
+	}
+	return nil
+}
+
+// process summarises the SpotBugs reports attached to act. The reports of every spotbugs attachment, e.g. one for
+// every module of a build, are merged into a single summary
+func (a *analyzer) process(act *jenkinsv1.PipelineActivity) {
+	a.costs.recordAnnotation(act)
+	reportURLs := spotBugsURLs(act)
+	if len(reportURLs) == 0 {
+		return
+	}
+	if act.Annotations[annotationReportURL] == strings.Join(reportURLs, " ") {
+		// Already summarised
+		return
+	}
+	filters, err := a.filters.forActivity(act)
 	if err != nil {
-		return err
+		log.Println(err)
+		return
 	}
-	watch, err := client.PipelineActivities(ns).Watch(metav1.ListOptions{})
+	// Reports fetched before are revalidated with a conditional request, so an unchanged report isn't downloaded
+	// again. A summary of only some of the reports would be misleading, so none is written unless all can be read
+	raw, err := parseSpotBugsReports(reportURLs, a.fetcher, a.maxSize)
 	if err != nil {
-		return err
+		log.Println(errors.Wrap(err, fmt.Sprintf("Unable to retrieve the reports of PipelineActivity %s", act.Name)))
+		if rejected, ok := fetch.IsRejected(err); ok {
+			a.recordRejected(act, rejected.URL, rejected)
+		}
+		return
+	}
+	for _, warning := range checkTotals(raw) {
+		log.Printf("Warning: report of PipelineActivity %s: %s\n", act.Name, warning)
+	}
+	completeness := checkCompleteness(raw)
+	if !completeness.complete() {
+		log.Printf("Warning: report of PipelineActivity %s: %s\n", act.Name, completeness)
+	}
+	// A report is only summarised once its filters could be read, as the summary would otherwise count the bugs they
+	// suppress
+	bugCollection, err := filters.apply(raw, a.fetcher)
+	if err != nil {
+		log.Println(errors.Wrap(err, fmt.Sprintf("Unable to filter the reports of PipelineActivity %s", act.Name)))
+		if rejected, ok := fetch.IsRejected(err); ok {
+			a.recordRejected(act, rejected.URL, rejected)
+		}
+		return
+	}
+	// Pipelines mustn't be able to pass the quality gate by annotating their activity with filters suppressing the
+	// bugs which fail it, so it only takes the analyzer's own filters into account
+	gated := bugCollection
+	if a.config.gateEnabled() && hasAnnotatedFilters(act) {
+		gated, err = a.filters.apply(raw, a.fetcher)
+		if err != nil {
+			log.Println(errors.Wrap(err, fmt.Sprintf("Unable to filter the reports of PipelineActivity %s", act.Name)))
+			return
+		}
+	}
+	config := a.config.forActivity(act)
+	gate := config.gate(gated)
+	summary := summarise(bugCollection, config)
+	if !filters.empty() {
+		summary.filtered(summarise(raw, config))
+	}
+	summary.gated(gate)
+	summary.Original = originalReport(bugCollection)
+	cost := newAnalysisCost(bugCollection.FindBugsSummary)
+	if regressions := a.costs.regressions(act, cost, a.config.CostRegressionFactor); len(regressions) > 0 {
+		log.Printf("Warning: analysis cost of PipelineActivity %s regressed: %s\n", act.Name,
+			strings.Join(regressions, "; "))
+		summary.Tags = append(summary.Tags, "analysis-cost:regressed")
 	}
+	if a.publicURL != "" {
+		summary.Tags = append(summary.Tags, "html-report:"+a.publicURL+reportPath(act.Namespace, act.Name))
+	}
+	annotations := map[string]string{
+		annotationFetchRejected:  "",
+		annotationReportURL:      strings.Join(reportURLs, " "),
+		annotationAnalyzedAt:     time.Now().UTC().Format(time.RFC3339),
+		annotationAnalysisCost:   cost.annotation(),
+		annotationAnalysisStatus: completeness.status(),
+		annotationRawTotals:      summary.rawAnnotation(),
+	}
+	if status := gate.status(); status != "" {
+		annotations[annotationQualityGate] = status
+	}
+	updated, err := patchSummary(a.client.PipelineActivities(act.Namespace), act, summary, annotations)
+	if err != nil {
+		log.Println(errors.Wrap(err, fmt.Sprintf("Error updating PipelineActivity %s", act.Name)))
+		return
+	}
+	a.costs.record(updated, cost)
+	log.Printf("Updated PipelineActivity %s with data from %s\n", act.Name, strings.Join(reportURLs, ", "))
+}
 
-	var httpClient = &http.Client{
-		Timeout: time.Second * 10,
+// spotBugsURLs returns the URLs of the spotbugs attachments of act, without duplicates
+func spotBugsURLs(act *jenkinsv1.PipelineActivity) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, attachment := range act.Spec.Attachments {
+		if attachment.Name != "spotbugs" {
+			continue
+		}
+		for _, reportURL := range attachment.URLs {
+			if reportURL != "" && !seen[reportURL] {
+				seen[reportURL] = true
+				result = append(result, reportURL)
+			}
+		}
 	}
+	return result
+}
 
-	for event := range watch.ResultChan() {
-		act, ok := event.Object.(*jenkinsv1.PipelineActivity)
-		if !ok {
-			log.Fatalf("unexpected type %s\n", event)
-		}
-		//
-		if act.Spec.Summaries.StaticProgramAnalysis.Name == "" {
-			for _, attachment := range act.Spec.Attachments {
-				if attachment.Name == "spotbugs" {
-					// TODO Handle having multiple attachments properly
-					for _, url := range attachment.URLs {
-						url = fmt.Sprintf("%s?version=%d", url, time.Now().UnixNano()/int64(time.Millisecond))
-						bugCollection, err := parseSpotBugsReport(url, httpClient)
-						if err != nil {
-							log.Println(errors.Wrap(err, fmt.Sprintf("Unable to retrieve %s for processing", url)))
-						}
-						// Create the summaries for the categories
-						categories := make(map[string]jenkinsv1.StaticProgramAnalysisCategory)
-						for _, b := range bugCollection.BugInstance {
-							category, ok := categories[b.Category]
-							if !ok {
-								category = jenkinsv1.StaticProgramAnalysisCategory{}
-							}
-							switch b.Priority {
-							case 1:
-								category.HighPriority++
-							case 2:
-								category.NormalPriority++
-							case 3:
-								category.LowPriority++
-							case 5:
-								category.Ignored++
-							}
-							categories[b.Category] = category
-						}
-						act.Spec.Summaries.StaticProgramAnalysis = jenkinsv1.StaticProgramAnalysis{
-							Name:           attachment.Name,
-							TotalBugs:      bugCollection.FindBugsSummary.TotalBugs,
-							HighPriority:   bugCollection.FindBugsSummary.HighPriority,
-							NormalPriority: bugCollection.FindBugsSummary.NormalPriority,
-							LowPriority:    bugCollection.FindBugsSummary.LowPriority,
-							Ignored:        bugCollection.FindBugsSummary.IgnorePriority,
-							TotalClasses:   bugCollection.FindBugsSummary.TotalClasses,
-							Categories:     categories,
-						}
-						act, err = client.PipelineActivities(act.Namespace).Update(act)
-						log.Printf("Updated PipelineActivity %s with data from %s\n", act.Name, url)
-						if err != nil {
-							log.Println(errors.Wrap(err, fmt.Sprintf("Error updating PipelineActivity %s", act.Name)))
-						}
-					}
-				}
+// recordRejected annotates act with the reason fetching location, a report or a filter, was rejected, unless it
+// already is
+func (a *analyzer) recordRejected(act *jenkinsv1.PipelineActivity, location string,
+	rejected *fetch.RejectedError) *jenkinsv1.PipelineActivity {
+	value := location + ": " + rejected.Reason
+	if act.Annotations[annotationFetchRejected] == value {
+		return act
+	}
+	updated, err := patchAnnotations(a.client.PipelineActivities(act.Namespace), act, map[string]string{
+		annotationFetchRejected: value,
+	})
+	if err != nil {
+		log.Println(errors.Wrap(err, fmt.Sprintf("Error updating PipelineActivity %s", act.Name)))
+		return act
+	}
+	return updated
+}
+
+// watchActivities starts a watch for PipelineActivities in every configured namespace and merges their events. The
+// returned channel is closed once all watches have ended, or stop is closed
+func watchActivities(client jenkinsclientv1.JenkinsV1Interface, config watchConfig,
+	stop <-chan struct{}) (<-chan k8swatch.Event, error) {
+	var watches []k8swatch.Interface
+	for _, ns := range config.watchNamespaces() {
+		w, err := client.PipelineActivities(ns).Watch(config.listOptions())
+		if err != nil {
+			for _, started := range watches {
+				started.Stop()
 			}
+			return nil, errors.Wrapf(err, "unable to watch PipelineActivities in namespace %q", ns)
 		}
+		watches = append(watches, w)
 	}
-	return nil
+	events := make(chan k8swatch.Event)
+	var wg sync.WaitGroup
+	for _, w := range watches {
+		wg.Add(1)
+		go func(w k8swatch.Interface) {
+			defer wg.Done()
+			for event := range w.ResultChan() {
+				events <- event
+			}
+		}(w)
+	}
+	go func() {
+		<-stop
+		for _, w := range watches {
+			w.Stop()
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+	return events, nil
 }
 
-func parseSpotBugsReport(url string, httpClient *http.Client) (collection findbugs.BugCollection, err error) {
-	response, err := httpClient.Get(url)
+// parseSpotBugsReport fetches and parses the report at location, fetcher selecting how by the scheme of location. The
+// report may be compressed or an archive of several reports, which are merged and may not exceed maxSize once
+// decompressed, 0 for no limit
+func parseSpotBugsReport(location string, fetcher fetch.ReportFetcher,
+	maxSize int64) (collection findbugs.BugCollection, err error) {
+	u, err := url.Parse(location)
 	if err != nil {
 		return findbugs.BugCollection{}, err
 	}
-	if response.StatusCode > 299 || response.StatusCode < 200 {
-		return findbugs.BugCollection{}, errors.New(fmt.Sprintf("Status code: %d, error: %s", response.StatusCode, response.Status))
-	}
-	body, err := ioutil.ReadAll(response.Body)
-	defer response.Body.Close()
+	parser := &reportParser{}
+	err = fetch.Reports(fetcher, u, maxSize, parser.parse)
 	if err != nil {
 		return findbugs.BugCollection{}, err
 	}
-	err = xml.Unmarshal(body, &collection)
+	return parser.result(location)
+}
+
+// parseSpotBugsReports fetches and parses the reports at locations like parseSpotBugsReport, merging them
+func parseSpotBugsReports(locations []string, fetcher fetch.ReportFetcher,
+	maxSize int64) (findbugs.BugCollection, error) {
+	collections := make([]findbugs.BugCollection, 0, len(locations))
+	for _, location := range locations {
+		collection, err := parseSpotBugsReport(location, fetcher, maxSize)
+		if err != nil {
+			return findbugs.BugCollection{}, errors.Wrapf(err, "unable to retrieve %s", location)
+		}
+		collections = append(collections, collection)
+	}
+	if len(collections) == 1 {
+		return collections[0], nil
+	}
+	return findbugs.Merge(collections...), nil
+}
+
+// parseReports parses the report in r, which may be compressed or an archive of several reports like those fetched by
+// parseSpotBugsReport
+func parseReports(name string, r io.Reader) (findbugs.BugCollection, error) {
+	parser := &reportParser{}
+	err := fetch.EachReport(name, r, 0, parser.parse)
 	if err != nil {
 		return findbugs.BugCollection{}, err
 	}
-	return collection, nil
+	return parser.result(name)
+}
+
+// reportParser parses the reports of an archive. Other XML documents, e.g. build files, are skipped
+type reportParser struct {
+	collections []findbugs.BugCollection
+	skipped     error
+}
+
+func (p *reportParser) parse(name string, r io.Reader) error {
+	collection, err := findbugs.Parse(r)
+	if err == findbugs.ErrNotReport {
+		p.skipped = errors.Wrap(err, name)
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "unable to parse %s", name)
+	}
+	p.collections = append(p.collections, collection)
+	return nil
+}
+
+// result merges the reports parsed
+func (p *reportParser) result(location string) (findbugs.BugCollection, error) {
+	switch {
+	case len(p.collections) > 0:
+		return findbugs.Merge(p.collections...), nil
+	case p.skipped != nil:
+		return findbugs.BugCollection{}, p.skipped
+	}
+	return findbugs.BugCollection{}, errors.Errorf("no reports found in %s", location)
 }
 
 func main() {
-	err := watch()
+	if len(os.Args) > 1 {
+		if cmd := findCommand(os.Args[1]); cmd != nil {
+			err := cmd.run(os.Args[2:], os.Stdout)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	flag.Usage = usage
+	kubeconfig := flag.String("kubeconfig", "", "Path to a kubeconfig file. Defaults to $KUBECONFIG or ~/.kube/config, falling back to the in-cluster configuration")
+	kubeContext := flag.String("context", "", "The kubeconfig context to use")
+	listen := flag.String("listen", ":8080", "The address the read-only API listens on, empty to disable it")
+	once := flag.Bool("once", false, "Summarise the existing PipelineActivities once and exit, instead of watching for changes")
+	flag.Parse()
+
+	config, contextNamespace, err := kube.LoadConfig(*kubeconfig, *kubeContext)
+	if err != nil {
+		panic(err.Error())
+	}
+	client, err := jenkinsclientv1.NewForConfig(config)
+	if err != nil {
+		panic(err.Error())
+	}
+	watched, err := watchConfigFromEnv(contextNamespace)
 	if err != nil {
 		panic(err.Error())
 	}
 
+	analysis, err := analysisConfigFromEnv()
+	if err != nil {
+		panic(err.Error())
+	}
+
+	fetchConfig, err := fetch.ConfigFromEnv()
+	if err != nil {
+		panic(err.Error())
+	}
+	credentials, err := credentialsConfigFromEnv()
+	if err != nil {
+		panic(err.Error())
+	}
+	if len(credentials.Secrets) > 0 {
+		secrets, err := kube.NewSecretClient(config)
+		if err != nil {
+			panic(err.Error())
+		}
+		fetchConfig.Credentials = fetch.NewCredentialStore()
+		loader := newCredentialsLoader(secrets, fetchConfig.Credentials, credentials)
+		// Load the credentials before the first report is fetched
+		loader.load()
+		go loader.run(nil)
+	}
+
+	a := newAnalyzer(client, fetch.New(fetchConfig), fetchConfig.Policy.MaxSize, os.Getenv("SPOTBUGS_PUBLIC_URL"),
+		analysis, filterConfigFromEnv())
+	if *once {
+		err = a.backfill(watched)
+		if err != nil {
+			panic(err.Error())
+		}
+		return
+	}
+
+	// Every replica serves the read-only API, only the leader processes events
+	status := &leaderStatus{}
+	if *listen != "" {
+		api := apiConfigFromEnv()
+		api.Watched = watched
+		api.Authenticated = fetchConfig.Authenticated
+		go func() {
+			log.Fatal(http.ListenAndServe(*listen, newAPIServer(a, status, api)))
+		}()
+	}
+
+	if os.Getenv("SPOTBUGS_LEADER_ELECT") == "true" {
+		err = runLeaderElection(config, a, watched, status)
+	} else {
+		status.set(true)
+		err = a.run(watched, nil)
+	}
+	if err != nil {
+		panic(err.Error())
+	}
 }
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	jenkinsv1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+func TestProcessMergesAttachments(t *testing.T) {
+	urls := []string{
+		"https://reports.example.com/demo/1/core/spotbugsXml.xml",
+		"https://reports.example.com/demo/1/web/spotbugsXml.xml",
+	}
+	act := &jenkinsv1.PipelineActivity{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "jx", Name: "demo-1"},
+		Spec: jenkinsv1.PipelineActivitySpec{
+			Attachments: []jenkinsv1.Attachment{
+				{Name: "spotbugs", URLs: urls[:1]},
+				{Name: "coverage", URLs: []string{"https://reports.example.com/demo/1/jacoco.xml"}},
+				{Name: "spotbugs", URLs: []string{urls[1], urls[0]}},
+			},
+		},
+	}
+	cluster, client := newFakeCluster(t, act)
+	fetcher := &fileFetcher{files: map[string]string{urls[0]: sampleReport, urls[1]: sampleReport}}
+	a := newAnalyzer(client, fetcher, 0, "", analysisConfig{Basis: basisPriority}, filterConfig{})
+
+	a.process(act)
+	if got := cluster.patchCount(); got != 1 {
+		t.Fatalf("got %d patches, want a single patch for both reports", got)
+	}
+	stored := cluster.activity("jx", "demo-1")
+	metadata := stored["metadata"].(map[string]interface{})
+	annotations := metadata["annotations"].(map[string]interface{})
+	if got, want := annotations[annotationReportURL], urls[0]+" "+urls[1]; got != want {
+		t.Errorf("got report URLs %q, want %q", got, want)
+	}
+	summary := stored["spec"].(map[string]interface{})["summaries"].(map[string]interface{})["staticProgramAnalysis"].(map[string]interface{})
+	if got := summary["totalBugs"]; got != float64(10) {
+		t.Errorf("got %v bugs, want the 10 bugs of both reports", got)
+	}
+
+	// The patch triggers a watch event for the updated activity, which mustn't be summarised again
+	updated, err := client.PipelineActivities("jx").Get("demo-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetches := fetcher.fetches
+	a.process(updated)
+	if got := cluster.patchCount(); got != 1 {
+		t.Errorf("got %d patches after processing the updated activity, want it to be skipped", got)
+	}
+	if fetcher.fetches != fetches {
+		t.Errorf("got %d fetches after processing the updated activity, want none", fetcher.fetches-fetches)
+	}
+
+	// A new report is summarised together with the others
+	updated.Spec.Attachments = append(updated.Spec.Attachments, jenkinsv1.Attachment{
+		Name: "spotbugs",
+		URLs: []string{"https://reports.example.com/demo/1/cli/spotbugsXml.xml"},
+	})
+	fetcher.files["https://reports.example.com/demo/1/cli/spotbugsXml.xml"] = sampleReport
+	a.process(updated)
+	if got := cluster.patchCount(); got != 2 {
+		t.Errorf("got %d patches, want the new report to be summarised", got)
+	}
+}
+
+func TestProcessSkipsPartialReports(t *testing.T) {
+	act := &jenkinsv1.PipelineActivity{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "jx", Name: "demo-2"},
+		Spec: jenkinsv1.PipelineActivitySpec{
+			Attachments: []jenkinsv1.Attachment{{
+				Name: "spotbugs",
+				URLs: []string{"https://reports.example.com/a.xml", "https://reports.example.com/missing.xml"},
+			}},
+		},
+	}
+	cluster, client := newFakeCluster(t, act)
+	fetcher := &fileFetcher{files: map[string]string{"https://reports.example.com/a.xml": sampleReport}}
+	a := newAnalyzer(client, fetcher, 0, "", analysisConfig{Basis: basisPriority}, filterConfig{})
+	a.process(act)
+	if got := cluster.patchCount(); got != 0 {
+		t.Errorf("got %d patches, want none while a report is missing", got)
+	}
+}
+
+func TestProcessFiltersReports(t *testing.T) {
+	act := &jenkinsv1.PipelineActivity{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "jx", Name: "demo-4"},
+		Spec: jenkinsv1.PipelineActivitySpec{
+			Attachments: []jenkinsv1.Attachment{{Name: "spotbugs", URLs: []string{sampleReportURL}}},
+		},
+	}
+	cluster, client := newFakeCluster(t, act)
+	fetcher := &fileFetcher{files: map[string]string{sampleReportURL: sampleReport}}
+	a := newAnalyzer(client, fetcher, 0, "", analysisConfig{Basis: basisPriority},
+		filterConfig{Exclude: []string{"testdata/exclude.xml"}})
+	a.process(act)
+	stored := cluster.activity("jx", "demo-4")
+	annotations := stored["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})
+	var raw rawTotals
+	if err := json.Unmarshal([]byte(annotations[annotationRawTotals].(string)), &raw); err != nil {
+		t.Fatalf("invalid raw totals %v: %v", annotations[annotationRawTotals], err)
+	}
+	if want := (rawTotals{TotalBugs: 5, HighPriority: 2, NormalPriority: 1, LowPriority: 1,
+		ExperimentalPriority: 1}); raw != want {
+		t.Errorf("got raw totals %+v, want %+v", raw, want)
+	}
+	summary := stored["spec"].(map[string]interface{})["summaries"].(map[string]interface{})["staticProgramAnalysis"].(map[string]interface{})
+	if got := summary["totalBugs"]; got != float64(3) {
+		t.Errorf("got %v bugs, want the 3 bugs left by the filter", got)
+	}
+	if _, ok := summary["raw"]; ok {
+		t.Error("the summary has the field raw, which the CRD doesn't define")
+	}
+}
+
+func TestProcessGateIgnoresAnnotatedFilters(t *testing.T) {
+	const filterURL = "https://reports.example.com/demo/exclude-high.xml"
+	tests := []struct {
+		name      string
+		filters   filterConfig
+		annotated bool
+		wantHigh  float64
+		wantGate  string
+	}{
+		{name: "unfiltered", wantHigh: 2, wantGate: "failed"},
+		{name: "configured", filters: filterConfig{Exclude: []string{"testdata/exclude-high.xml"}}, wantHigh: 0,
+			wantGate: "passed"},
+		{name: "annotated", annotated: true, wantHigh: 0, wantGate: "failed"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			act := &jenkinsv1.PipelineActivity{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "jx", Name: "demo-5"},
+				Spec: jenkinsv1.PipelineActivitySpec{
+					Attachments: []jenkinsv1.Attachment{{Name: "spotbugs", URLs: []string{sampleReportURL}}},
+				},
+			}
+			if test.annotated {
+				act.Annotations = map[string]string{annotationExcludeFilters: filterURL}
+			}
+			cluster, client := newFakeCluster(t, act)
+			fetcher := &fileFetcher{files: map[string]string{
+				sampleReportURL: sampleReport,
+				filterURL:       "testdata/exclude-high.xml",
+			}}
+			config := analysisConfig{Basis: basisPriority, GatePriority: findbugs.PriorityHigh}
+			newAnalyzer(client, fetcher, 0, "", config, test.filters).process(act)
+			stored := cluster.activity("jx", "demo-5")
+			annotations := stored["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})
+			if got := annotations[annotationQualityGate]; got != test.wantGate {
+				t.Errorf("got quality gate %v, want %s", got, test.wantGate)
+			}
+			summary := stored["spec"].(map[string]interface{})["summaries"].(map[string]interface{})["staticProgramAnalysis"].(map[string]interface{})
+			// Counts of 0 are omitted
+			if got, _ := summary["highPriority"].(float64); got != test.wantHigh {
+				t.Errorf("got %v bugs of high priority, want %v", got, test.wantHigh)
+			}
+			found := false
+			for _, tag := range summary["tags"].([]interface{}) {
+				found = found || tag == "quality-gate:"+test.wantGate
+			}
+			if !found {
+				t.Errorf("got tags %v, want quality-gate:%s", summary["tags"], test.wantGate)
+			}
+		})
+	}
+}
+
+func TestRunWatchesAgain(t *testing.T) {
+	act := &jenkinsv1.PipelineActivity{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "jx", Name: "demo-3"},
+		Spec: jenkinsv1.PipelineActivitySpec{
+			Attachments: []jenkinsv1.Attachment{{Name: "spotbugs", URLs: []string{sampleReportURL}}},
+		},
+	}
+	cluster, client := newFakeCluster(t, act)
+	fetcher := &fileFetcher{files: map[string]string{sampleReportURL: sampleReport}}
+	a := newAnalyzer(client, fetcher, 0, "", analysisConfig{Basis: basisPriority}, filterConfig{})
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- a.run(watchConfig{Namespaces: []string{"jx"}, Workers: 1}, stop)
+	}()
+	deadline := time.Now().Add(10 * time.Second)
+	for cluster.watchCount() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("the watch wasn't started again after it ended")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(stop)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("run didn't return once stopped")
+	}
+	if got := cluster.patchCount(); got != 1 {
+		t.Errorf("got %d patches, want the activity to be summarised once", got)
+	}
+}
+
+func TestRunSurvivesErrorAndDeletedEvents(t *testing.T) {
+	act := &jenkinsv1.PipelineActivity{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "jx", Name: "demo-4"},
+		Spec: jenkinsv1.PipelineActivitySpec{
+			Attachments: []jenkinsv1.Attachment{{Name: "spotbugs", URLs: []string{sampleReportURL}}},
+		},
+	}
+	deletedURL := "https://reports.example.com/demo/5/spotbugsXml.xml"
+	deleted := &jenkinsv1.PipelineActivity{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "jenkins.io/v1", Kind: "PipelineActivity"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "jx", Name: "demo-5", ResourceVersion: "3"},
+		Spec: jenkinsv1.PipelineActivitySpec{
+			Attachments: []jenkinsv1.Attachment{{Name: "spotbugs", URLs: []string{deletedURL}}},
+		},
+	}
+	cluster, client := newFakeCluster(t, act)
+	deletedObject, err := toJSONMap(deleted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster.watchEvents = []map[string]interface{}{
+		{"type": "DELETED", "object": deletedObject},
+		{"type": "ERROR", "object": map[string]interface{}{
+			"kind":       "Status",
+			"apiVersion": "v1",
+			"status":     "Failure",
+			"reason":     "Expired",
+			"code":       410,
+		}},
+	}
+	fetcher := &fileFetcher{files: map[string]string{sampleReportURL: sampleReport, deletedURL: sampleReport}}
+	a := newAnalyzer(client, fetcher, 0, "", analysisConfig{Basis: basisPriority}, filterConfig{})
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- a.run(watchConfig{Namespaces: []string{"jx"}, Workers: 1}, stop)
+	}()
+	// An error event ends the watch, which is started again
+	deadline := time.Now().Add(10 * time.Second)
+	for cluster.watchCount() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("the watch wasn't started again after an error event")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(stop)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("run didn't return once stopped")
+	}
+	if got := cluster.patchCount(); got != 1 {
+		t.Errorf("got %d patches, want the activity to be summarised once", got)
+	}
+	if got := fetcher.fetches; got != 1 {
+		t.Errorf("got %d fetches, want only the report of the activity which wasn't deleted to be fetched", got)
+	}
+}
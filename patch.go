@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	jenkinsv1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	jenkinsclientv1 "github.com/jenkins-x/jx/pkg/client/clientset/versioned/typed/jenkins.io/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// annotationReportURL records the URLs of the reports the summary was computed from, separated by spaces
+	annotationReportURL = "spotbugs.jenkins-x.io/report-url"
+	// annotationAnalyzedAt records when the summary was written
+	annotationAnalyzedAt = "spotbugs.jenkins-x.io/analyzed-at"
+	// annotationQualityGate records whether the report passed or failed the quality gate, if one is configured
+	annotationQualityGate = "spotbugs.jenkins-x.io/quality-gate"
+	// annotationEffort and annotationThreshold are set by pipelines which run SpotBugs with an effort or threshold
+	// other than the one configured for the analyzer
+	annotationEffort    = "spotbugs.jenkins-x.io/effort"
+	annotationThreshold = "spotbugs.jenkins-x.io/threshold"
+	// annotationAnalysisStatus records whether SpotBugs analyzed all code, complete or incomplete
+	annotationAnalysisStatus = "spotbugs.jenkins-x.io/analysis-status"
+	// annotationAnalysisCost records the time and memory SpotBugs needed to produce the report, as JSON
+	annotationAnalysisCost = "spotbugs.jenkins-x.io/analysis-cost"
+	// annotationFetchRejected records why fetching a report attached to the activity was rejected
+	annotationFetchRejected = "spotbugs.jenkins-x.io/fetch-rejected"
+	// annotationIncludeFilters and annotationExcludeFilters are set by pipelines whose reports are filtered in addition
+	// to the filters configured for the analyzer, as comma separated URLs of SpotBugs filter files
+	annotationIncludeFilters = "spotbugs.jenkins-x.io/include-filters"
+	annotationExcludeFilters = "spotbugs.jenkins-x.io/exclude-filters"
+	// annotationRawTotals records the totals of the reports before filters were applied, as JSON, if they were filtered
+	annotationRawTotals = "spotbugs.jenkins-x.io/raw-totals"
+)
+
+// conflictBackoff mirrors the default retry used by client-go when updating objects
+var conflictBackoff = wait.Backoff{
+	Steps:    5,
+	Duration: 10 * time.Millisecond,
+	Factor:   1.0,
+	Jitter:   0.1,
+}
+
+// patchSummary writes summary and annotations to act, removing annotations with an empty value, using a JSON merge
+// patch which only touches spec.summaries.staticProgramAnalysis and the analyzer's own annotations, so changes made to
+// the activity by other controllers (e.g. step statuses) are never overwritten. The patch is guarded by the
+// resourceVersion of act; if the activity has changed in the meantime the latest version is retrieved and the patch
+// recomputed.
+func patchSummary(activities jenkinsclientv1.PipelineActivityInterface, act *jenkinsv1.PipelineActivity,
+	summary analysisSummary, annotations map[string]string) (*jenkinsv1.PipelineActivity, error) {
+	return patchLatest(activities, act, func(act *jenkinsv1.PipelineActivity) ([]byte, error) {
+		return summaryPatch(act, summary, annotations)
+	})
+}
+
+// patchLatest applies the JSON merge patch computed by patchFor to act. If the patch conflicts because act has changed
+// in the meantime the latest version of act is retrieved and the patch computed for it, until conflictBackoff is
+// exhausted
+func patchLatest(activities jenkinsclientv1.PipelineActivityInterface, act *jenkinsv1.PipelineActivity,
+	patchFor func(act *jenkinsv1.PipelineActivity) ([]byte, error)) (*jenkinsv1.PipelineActivity, error) {
+	var result *jenkinsv1.PipelineActivity
+	err := retryOnConflict(func() error {
+		patch, err := patchFor(act)
+		if err != nil {
+			return err
+		}
+		result, err = activities.Patch(act.Name, types.MergePatchType, patch)
+		if apierrors.IsConflict(err) {
+			latest, getErr := activities.Get(act.Name, metav1.GetOptions{})
+			if getErr != nil {
+				return getErr
+			}
+			act = latest
+		}
+		return err
+	})
+	return result, err
+}
+
+// retryOnConflict runs fn until it succeeds, returns an error other than a conflict, or conflictBackoff is exhausted
+func retryOnConflict(fn func() error) error {
+	var lastConflict error
+	err := wait.ExponentialBackoff(conflictBackoff, func() (bool, error) {
+		err := fn()
+		switch {
+		case err == nil:
+			return true, nil
+		case apierrors.IsConflict(err):
+			lastConflict = err
+			return false, nil
+		default:
+			return false, err
+		}
+	})
+	if err == wait.ErrWaitTimeout {
+		err = lastConflict
+	}
+	return err
+}
+
+// summaryPatch creates a JSON merge patch which replaces the static program analysis summary of act with summary and
+// sets annotations. Fields and categories which are present on act but not in summary are explicitly removed, as a
+// merge patch would otherwise leave them in place.
+func summaryPatch(act *jenkinsv1.PipelineActivity, summary analysisSummary,
+	annotations map[string]string) ([]byte, error) {
+	current, err := toJSONMap(act.Spec.Summaries.StaticProgramAnalysis)
+	if err != nil {
+		return nil, err
+	}
+	current["original"] = originalJSON(act.Spec.Summaries.StaticProgramAnalysis.Original)
+	desired, err := summary.jsonMap()
+	if err != nil {
+		return nil, err
+	}
+	metadata := map[string]interface{}{
+		"resourceVersion": act.ResourceVersion,
+	}
+	if len(annotations) > 0 {
+		metadata["annotations"] = annotationsPatch(annotations)
+	}
+	patch := map[string]interface{}{
+		"metadata": metadata,
+		"spec": map[string]interface{}{
+			"summaries": map[string]interface{}{
+				"staticProgramAnalysis": mergeDiff(current, desired),
+			},
+		},
+	}
+	return json.Marshal(patch)
+}
+
+// patchAnnotations sets annotations on act, removing those with an empty value. Like patchSummary the patch is guarded
+// by the resourceVersion of act
+func patchAnnotations(activities jenkinsclientv1.PipelineActivityInterface, act *jenkinsv1.PipelineActivity,
+	annotations map[string]string) (*jenkinsv1.PipelineActivity, error) {
+	return patchLatest(activities, act, func(act *jenkinsv1.PipelineActivity) ([]byte, error) {
+		return json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"resourceVersion": act.ResourceVersion,
+				"annotations":     annotationsPatch(annotations),
+			},
+		})
+	})
+}
+
+// annotationsPatch returns annotations for a JSON merge patch, in which a null value removes an annotation
+func annotationsPatch(annotations map[string]string) map[string]interface{} {
+	result := make(map[string]interface{}, len(annotations))
+	for key, value := range annotations {
+		if value == "" {
+			result[key] = nil
+		} else {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// mergeDiff returns desired with every key which is only present in current set to nil, recursing into nested objects
+func mergeDiff(current, desired map[string]interface{}) map[string]interface{} {
+	for key, value := range current {
+		desiredValue, ok := desired[key]
+		if !ok {
+			desired[key] = nil
+			continue
+		}
+		currentObject, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if desiredObject, ok := desiredValue.(map[string]interface{}); ok {
+			desired[key] = mergeDiff(currentObject, desiredObject)
+		}
+	}
+	return desired
+}
+
+// originalJSON encodes o. MimeType and URL of jenkinsv1.Original are both tagged "mimetype", so encoding/json drops
+// both of them; the mime type is written explicitly. The URL isn't written as the CRD has no field for it, the URLs
+// of the reports are kept in the report-url annotation instead
+func originalJSON(o jenkinsv1.Original) map[string]interface{} {
+	result := make(map[string]interface{})
+	if o.MimeType != "" {
+		result["mimetype"] = o.MimeType
+	}
+	if len(o.Tags) > 0 {
+		result["tags"] = o.Tags
+	}
+	return result
+}
+
+func toJSONMap(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{})
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	jenkinsv1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMergeDiff(t *testing.T) {
+	current := map[string]interface{}{
+		"totalBugs": 3.0,
+		"ignored":   1.0,
+		"categories": map[string]interface{}{
+			"CORRECTNESS": map[string]interface{}{"highPriority": 1.0, "lowPriority": 2.0},
+			"STYLE":       map[string]interface{}{"lowPriority": 1.0},
+		},
+		"tags": []interface{}{"tool:spotbugs"},
+	}
+	desired := map[string]interface{}{
+		"totalBugs": 1.0,
+		"categories": map[string]interface{}{
+			"CORRECTNESS": map[string]interface{}{"highPriority": 1.0},
+		},
+		"tags": []interface{}{"tool:spotbugs", "basis:priority"},
+	}
+	want := map[string]interface{}{
+		"totalBugs": 1.0,
+		"ignored":   nil,
+		"categories": map[string]interface{}{
+			"CORRECTNESS": map[string]interface{}{"highPriority": 1.0, "lowPriority": nil},
+			"STYLE":       nil,
+		},
+		"tags": []interface{}{"tool:spotbugs", "basis:priority"},
+	}
+	if got := mergeDiff(current, desired); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSummaryPatch(t *testing.T) {
+	act := &jenkinsv1.PipelineActivity{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "jx", Name: "demo-1", ResourceVersion: "7"},
+		Spec: jenkinsv1.PipelineActivitySpec{
+			Summaries: jenkinsv1.Summaries{
+				StaticProgramAnalysis: jenkinsv1.StaticProgramAnalysis{
+					TotalBugs: 2,
+					Categories: map[string]jenkinsv1.StaticProgramAnalysisCategory{
+						"CORRECTNESS": {HighPriority: 1},
+						"STYLE":       {LowPriority: 1},
+					},
+					Original: jenkinsv1.Original{MimeType: "text/xml", Tags: []string{toolName}},
+				},
+			},
+		},
+	}
+	summary := analysisSummary{
+		StaticProgramAnalysis: jenkinsv1.StaticProgramAnalysis{
+			TotalBugs: 1,
+			Categories: map[string]jenkinsv1.StaticProgramAnalysisCategory{
+				"CORRECTNESS": {HighPriority: 1},
+			},
+			Original: jenkinsv1.Original{MimeType: reportMimeType},
+		},
+	}
+	data, err := summaryPatch(act, summary, map[string]string{annotationReportURL: "", annotationAnalysisStatus: "complete"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": "7",
+			"annotations": map[string]interface{}{
+				annotationReportURL:      nil,
+				annotationAnalysisStatus: "complete",
+			},
+		},
+		"spec": map[string]interface{}{
+			"summaries": map[string]interface{}{
+				"staticProgramAnalysis": map[string]interface{}{
+					"totalBugs": 1.0,
+					"categories": map[string]interface{}{
+						"CORRECTNESS": map[string]interface{}{"highPriority": 1.0},
+						"STYLE":       nil,
+					},
+					"original": map[string]interface{}{"mimetype": reportMimeType, "tags": nil},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got patch\n%s", data)
+	}
+}
+
+func TestAnnotationsPatch(t *testing.T) {
+	got := annotationsPatch(map[string]string{annotationQualityGate: "passed", annotationFetchRejected: ""})
+	want := map[string]interface{}{annotationQualityGate: "passed", annotationFetchRejected: nil}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPatchSummaryRetriesOnConflict(t *testing.T) {
+	act := &jenkinsv1.PipelineActivity{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "jx", Name: "demo-1"},
+	}
+	cluster, client := newFakeCluster(t, act)
+	// Another controller changes the activity after it was read
+	cluster.mu.Lock()
+	metadata := cluster.activities["jx/demo-1"]["metadata"].(map[string]interface{})
+	metadata["resourceVersion"] = "2"
+	metadata["annotations"] = map[string]interface{}{"example.com/other": "kept"}
+	cluster.mu.Unlock()
+
+	summary := analysisSummary{StaticProgramAnalysis: jenkinsv1.StaticProgramAnalysis{TotalBugs: 5}}
+	result, err := patchSummary(client.PipelineActivities("jx"), act, summary,
+		map[string]string{annotationAnalysisStatus: "complete"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cluster.patchCount(); got != 1 {
+		t.Errorf("got %d patches applied, want the patch to be applied once to the latest version", got)
+	}
+	if result.ResourceVersion != "3" || result.Spec.Summaries.StaticProgramAnalysis.TotalBugs != 5 {
+		t.Errorf("got version %s with %d bugs, want version 3 with 5 bugs", result.ResourceVersion,
+			result.Spec.Summaries.StaticProgramAnalysis.TotalBugs)
+	}
+	if got := result.Annotations["example.com/other"]; got != "kept" {
+		t.Errorf("got annotation %q, want the change of the other controller to be kept", got)
+	}
+	if got := result.Annotations[annotationAnalysisStatus]; got != "complete" {
+		t.Errorf("got analysis status %q, want complete", got)
+	}
+}
+
+func TestPatchAnnotationsRetriesOnConflict(t *testing.T) {
+	act := &jenkinsv1.PipelineActivity{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "jx", Name: "demo-1", Annotations: map[string]string{
+			annotationFetchRejected: "too large",
+		}},
+	}
+	cluster, client := newFakeCluster(t, act)
+	cluster.mu.Lock()
+	metadata := cluster.activities["jx/demo-1"]["metadata"].(map[string]interface{})
+	metadata["resourceVersion"] = "2"
+	metadata["annotations"].(map[string]interface{})["example.com/other"] = "kept"
+	cluster.mu.Unlock()
+
+	result, err := patchAnnotations(client.PipelineActivities("jx"), act,
+		map[string]string{annotationQualityGate: "passed", annotationFetchRejected: ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cluster.patchCount(); got != 1 {
+		t.Errorf("got %d patches applied, want the patch to be applied once to the latest version", got)
+	}
+	want := map[string]string{"example.com/other": "kept", annotationQualityGate: "passed"}
+	if !reflect.DeepEqual(result.Annotations, want) {
+		t.Errorf("got annotations %v, want %v", result.Annotations, want)
+	}
+}
@@ -0,0 +1,72 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+// WriteCompiler writes one line per finding in the format used by compilers, e.g.
+//
+//	com/example/Foo.java:42: [HIGH] NP_NULL_ON_SOME_PATH Possible null pointer dereference of s in Foo.bar()
+//
+// so that editors and log viewers link to the offending line. Findings without a source file are reported against
+// their class.
+func WriteCompiler(w io.Writer, findings []Finding) error {
+	for _, f := range findings {
+		location := f.File
+		if location == "" {
+			location = f.Class
+		}
+		if f.Line > 0 {
+			location = fmt.Sprintf("%s:%d", location, f.Line)
+		}
+		_, err := fmt.Fprintf(w, "%s: [%s] %s %s\n", location, strings.ToUpper(f.Priority), f.Pattern, f.Message)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteGitHub writes findings as GitHub Actions workflow commands, which annotate the offending lines. High priority
+// findings are errors, normal priority findings warnings and everything else notices.
+func WriteGitHub(w io.Writer, findings []Finding) error {
+	for _, f := range findings {
+		var properties []string
+		if f.File != "" {
+			properties = append(properties, "file="+escapeProperty(f.File))
+			if f.Line > 0 {
+				properties = append(properties, fmt.Sprintf("line=%d", f.Line))
+			}
+		}
+		properties = append(properties, "title="+escapeProperty(f.Pattern))
+		_, err := fmt.Fprintf(w, "::%s %s::%s\n", githubLevel(f.Priority), strings.Join(properties, ","),
+			escapeData(f.Message))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func githubLevel(priority string) string {
+	switch priority {
+	case findbugs.PriorityName(findbugs.PriorityHigh):
+		return "error"
+	case findbugs.PriorityName(findbugs.PriorityNormal):
+		return "warning"
+	default:
+		return "notice"
+	}
+}
+
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
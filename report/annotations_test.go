@@ -0,0 +1,71 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteGitHub(t *testing.T) {
+	tests := []struct {
+		name    string
+		finding Finding
+		want    string
+	}{
+		{
+			name: "error",
+			finding: Finding{Pattern: "NP_NULL_ON_SOME_PATH", Priority: "high", File: "com/example/Dao.java", Line: 12,
+				Message: "Possible null pointer dereference"},
+			want: "::error file=com/example/Dao.java,line=12,title=NP_NULL_ON_SOME_PATH::Possible null pointer dereference\n",
+		},
+		{
+			name:    "warning without a line",
+			finding: Finding{Pattern: "DM_DEFAULT_ENCODING", Priority: "normal", File: "Main.java", Message: "m"},
+			want:    "::warning file=Main.java,title=DM_DEFAULT_ENCODING::m\n",
+		},
+		{
+			name:    "notice without a file",
+			finding: Finding{Pattern: "URF_UNREAD_FIELD", Priority: "experimental", Line: 3, Message: "m"},
+			want:    "::notice title=URF_UNREAD_FIELD::m\n",
+		},
+		{
+			name: "escaped message",
+			finding: Finding{Pattern: "SQL", Priority: "low",
+				Message: "100% of\r\nthe query, with a:b::c"},
+			want: "::notice title=SQL::100%25 of%0D%0Athe query, with a:b::c\n",
+		},
+		{
+			name: "escaped properties",
+			finding: Finding{Pattern: "A:B,C%\n", Priority: "high", File: "dir,1/C:%d.java\r", Line: 1,
+				Message: "m"},
+			want: "::error file=dir%2C1/C%3A%25d.java%0D,line=1,title=A%3AB%2CC%25%0A::m\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteGitHub(&buf, []Finding{test.finding}); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteCompiler(t *testing.T) {
+	findings := []Finding{
+		{Pattern: "NP_NULL_ON_SOME_PATH", Priority: "high", Class: "com.example.Dao", File: "com/example/Dao.java",
+			Line: 12, Message: "Possible null pointer dereference"},
+		{Pattern: "URF_UNREAD_FIELD", Priority: "experimental", Class: "com.example.Row", Message: "Unread field"},
+	}
+	var buf bytes.Buffer
+	if err := WriteCompiler(&buf, findings); err != nil {
+		t.Fatal(err)
+	}
+	want := "com/example/Dao.java:12: [HIGH] NP_NULL_ON_SOME_PATH Possible null pointer dereference\n" +
+		"com.example.Row: [EXPERIMENTAL] URF_UNREAD_FIELD Unread field\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
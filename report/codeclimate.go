@@ -0,0 +1,102 @@
+package report
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+// codeClimateCategories maps SpotBugs categories to the categories of the Code Climate spec, anything else is a
+// "Bug Risk"
+var codeClimateCategories = map[string]string{
+	"I18N":           "Compatibility",
+	"MALICIOUS_CODE": "Security",
+	"PERFORMANCE":    "Performance",
+	"SECURITY":       "Security",
+	"STYLE":          "Style",
+}
+
+// CodeClimateIssue is an issue of the Code Climate spec, which is also the format of GitLab Code Quality reports
+type CodeClimateIssue struct {
+	Type        string              `json:"type"`
+	CheckName   string              `json:"check_name"`
+	Description string              `json:"description"`
+	Categories  []string            `json:"categories"`
+	Location    CodeClimateLocation `json:"location"`
+	Severity    string              `json:"severity"`
+	Fingerprint string              `json:"fingerprint"`
+}
+
+// CodeClimateLocation is the location of a CodeClimateIssue
+type CodeClimateLocation struct {
+	Path  string           `json:"path"`
+	Lines CodeClimateLines `json:"lines"`
+}
+
+// CodeClimateLines are the lines of a CodeClimateLocation
+type CodeClimateLines struct {
+	Begin int `json:"begin"`
+	End   int `json:"end,omitempty"`
+}
+
+// NewCodeClimateIssue converts b to a Code Climate issue. sourceRoot is the directory, relative to the root of the
+// repository, the source paths of the report are relative to.
+func NewCodeClimateIssue(b findbugs.BugInstance, sourceRoot string) CodeClimateIssue {
+	location := b.Location()
+	filePath := location.SourcePath
+	if filePath == "" {
+		// GitLab needs a path; the conventional source file of the class is the best guess left
+		filePath = classSourcePath(b.Class.ClassName)
+	}
+	category, ok := codeClimateCategories[b.Category]
+	if !ok {
+		category = "Bug Risk"
+	}
+	begin := location.Start
+	if begin <= 0 {
+		begin = 1
+	}
+	description := b.LongMessage
+	if description == "" {
+		description = b.ShortMessage
+	}
+	return CodeClimateIssue{
+		Type:        "issue",
+		CheckName:   b.Type,
+		Description: description,
+		Categories:  []string{category},
+		Location: CodeClimateLocation{
+			Path: path.Join(sourceRoot, filePath),
+			Lines: CodeClimateLines{
+				Begin: begin,
+				End:   location.End,
+			},
+		},
+		Severity:    SeverityOf(b.Priority, b.Rank).String(),
+		Fingerprint: fingerprint(b),
+	}
+}
+
+// fingerprint identifies b across builds. It is derived from the instance hash, which is the same for bugs found in
+// the same method of the same class, so the occurrence number of the bug is included to keep them apart
+func fingerprint(b findbugs.BugInstance) string {
+	sum := md5.Sum([]byte(fmt.Sprintf("%s|%d", b.Key(), b.InstanceOccurenceNum)))
+	return hex.EncodeToString(sum[:])
+}
+
+// WriteCodeClimate writes the bugs of bugCollection as a JSON array of Code Climate issues, the format of GitLab Code
+// Quality reports. See NewCodeClimateIssue for sourceRoot.
+func WriteCodeClimate(w io.Writer, bugCollection findbugs.BugCollection, sourceRoot string) error {
+	issues := make([]CodeClimateIssue, 0, len(bugCollection.BugInstance))
+	for _, b := range bugCollection.BugInstance {
+		issues = append(issues, NewCodeClimateIssue(b, sourceRoot))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(issues)
+}
@@ -0,0 +1,50 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+func TestCodeClimateIssuePath(t *testing.T) {
+	tests := []struct {
+		name string
+		bug  findbugs.BugInstance
+		want string
+	}{
+		{
+			name: "source path",
+			bug: findbugs.BugInstance{
+				Class:      findbugs.Class{ClassName: "com.example.Dao"},
+				SourceLine: findbugs.SourceLine{SourcePath: "com/example/Dao.java", Start: 12},
+			},
+			want: "src/main/java/com/example/Dao.java",
+		},
+		{
+			name: "no source path",
+			bug:  findbugs.BugInstance{Class: findbugs.Class{ClassName: "com.example.Dao"}},
+			want: "src/main/java/com/example/Dao.java",
+		},
+		{
+			name: "no source path for an inner class",
+			bug:  findbugs.BugInstance{Class: findbugs.Class{ClassName: "com.example.Dao$Row$1"}},
+			want: "src/main/java/com/example/Dao.java",
+		},
+		{
+			name: "no source path in the default package",
+			bug:  findbugs.BugInstance{Class: findbugs.Class{ClassName: "Main"}},
+			want: "src/main/java/Main.java",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			issue := NewCodeClimateIssue(test.bug, "src/main/java")
+			if got := issue.Location.Path; got != test.want {
+				t.Errorf("got path %s, want %s", got, test.want)
+			}
+			if got := issue.Location.Lines.Begin; got < 1 {
+				t.Errorf("got line %d, want a line GitLab accepts", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// The mime types of the findings written by WriteCSV and WriteJSONLines
+const (
+	CSVMimeType       = "text/csv"
+	JSONLinesMimeType = "application/x-ndjson"
+)
+
+// ExportContext identifies the build a report was produced by, it is added to every row exported by WriteCSV and
+// WriteJSONLines
+type ExportContext struct {
+	Repository string
+	Build      string
+	Commit     string
+}
+
+// ExportRow is a single finding together with the build it was found by, as exported by WriteCSV and WriteJSONLines
+type ExportRow struct {
+	Repository   string `json:"repository"`
+	Build        string `json:"build"`
+	Commit       string `json:"commit"`
+	Category     string `json:"category"`
+	Pattern      string `json:"pattern"`
+	Priority     string `json:"priority"`
+	Rank         int    `json:"rank"`
+	CWE          int    `json:"cwe"`
+	Class        string `json:"class"`
+	Method       string `json:"method"`
+	File         string `json:"file"`
+	Line         int    `json:"line"`
+	InstanceHash string `json:"instanceHash"`
+}
+
+// csvHeader names the columns of WriteCSV, in the order of the fields of ExportRow
+var csvHeader = []string{"repository", "build", "commit", "category", "pattern", "priority", "rank", "cwe", "class",
+	"method", "file", "line", "instance_hash"}
+
+// NewExportRow adds context to f
+func NewExportRow(context ExportContext, f Finding) ExportRow {
+	return ExportRow{
+		Repository:   context.Repository,
+		Build:        context.Build,
+		Commit:       context.Commit,
+		Category:     f.Category,
+		Pattern:      f.Pattern,
+		Priority:     f.Priority,
+		Rank:         f.Rank,
+		CWE:          f.CWE,
+		Class:        f.Class,
+		Method:       f.Method,
+		File:         f.File,
+		Line:         f.Line,
+		InstanceHash: f.InstanceHash,
+	}
+}
+
+// WriteCSV writes findings as CSV with a header row, one row per finding
+func WriteCSV(w io.Writer, context ExportContext, findings []Finding) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, f := range findings {
+		row := NewExportRow(context, f)
+		err := writer.Write([]string{
+			row.Repository,
+			row.Build,
+			row.Commit,
+			row.Category,
+			row.Pattern,
+			row.Priority,
+			optionalInt(row.Rank),
+			optionalInt(row.CWE),
+			row.Class,
+			row.Method,
+			row.File,
+			optionalInt(row.Line),
+			row.InstanceHash,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJSONLines writes findings as JSON Lines, one JSON object per finding
+func WriteJSONLines(w io.Writer, context ExportContext, findings []Finding) error {
+	encoder := json.NewEncoder(w)
+	for _, f := range findings {
+		if err := encoder.Encode(NewExportRow(context, f)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// optionalInt formats i, leaving the cell empty if it isn't set
+func optionalInt(i int) string {
+	if i == 0 {
+		return ""
+	}
+	return strconv.Itoa(i)
+}
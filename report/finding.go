@@ -0,0 +1,64 @@
+package report
+
+import (
+	"strings"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+// Finding is a flattened view of a single findbugs.BugInstance, suitable for tabular and line based formats
+type Finding struct {
+	Category     string `json:"category"`
+	Pattern      string `json:"pattern"`
+	Priority     string `json:"priority"`
+	Rank         int    `json:"rank"`
+	CWE          int    `json:"cwe,omitempty"`
+	Class        string `json:"class"`
+	Method       string `json:"method,omitempty"`
+	Field        string `json:"field,omitempty"`
+	File         string `json:"file,omitempty"`
+	Line         int    `json:"line,omitempty"`
+	Message      string `json:"message"`
+	InstanceHash string `json:"instanceHash,omitempty"`
+}
+
+// NewFinding flattens b
+func NewFinding(b findbugs.BugInstance) Finding {
+	location := b.Location()
+	message := b.LongMessage
+	if message == "" {
+		message = b.ShortMessage
+	}
+	return Finding{
+		Category:     b.Category,
+		Pattern:      b.Type,
+		Priority:     findbugs.PriorityName(b.Priority),
+		Rank:         b.Rank,
+		CWE:          b.Cweid,
+		Class:        b.Class.ClassName,
+		Method:       b.Method.Name,
+		Field:        b.Field.Name,
+		File:         location.SourcePath,
+		Line:         location.Start,
+		Message:      message,
+		InstanceHash: b.InstanceHash,
+	}
+}
+
+// Findings flattens bugs
+func Findings(bugs []findbugs.BugInstance) []Finding {
+	result := make([]Finding, 0, len(bugs))
+	for _, b := range bugs {
+		result = append(result, NewFinding(b))
+	}
+	return result
+}
+
+// classSourcePath returns the conventional path of the source file of className, e.g. com/example/Outer.java for
+// com.example.Outer$Inner, for bugs without a source path
+func classSourcePath(className string) string {
+	if i := strings.Index(className, "$"); i >= 0 {
+		className = className[:i]
+	}
+	return strings.Replace(className, ".", "/", -1) + ".java"
+}
@@ -0,0 +1,90 @@
+package report
+
+import (
+	"sort"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+// Hotspot is a package or class with bugs
+type Hotspot struct {
+	Name string `json:"name"`
+	Bugs int    `json:"bugs"`
+	// Size is the size of the code analyzed, in lines
+	Size int `json:"size"`
+	// Density is the number of bugs per thousand lines, 0 if the size is unknown
+	Density float64 `json:"density"`
+}
+
+// Hotspots are the packages and classes with the most bugs, both in total and relative to their size
+type Hotspots struct {
+	PackagesByBugs    []Hotspot `json:"packagesByBugs"`
+	PackagesByDensity []Hotspot `json:"packagesByDensity"`
+	ClassesByBugs     []Hotspot `json:"classesByBugs"`
+	ClassesByDensity  []Hotspot `json:"classesByDensity"`
+}
+
+// FindHotspots returns the top n packages and classes of bugCollection by number of bugs and by bugs per thousand lines,
+// using the package and class statistics of its FindBugsSummary. Packages and classes without bugs are left out, as
+// are those without a size from the rankings by density.
+func FindHotspots(bugCollection findbugs.BugCollection, n int) Hotspots {
+	var packages, classes []Hotspot
+	for _, p := range bugCollection.FindBugsSummary.PackageStats {
+		if p.TotalBugs > 0 {
+			packages = append(packages, newHotspot(p.Package, p.TotalBugs, p.TotalSize))
+		}
+		for _, c := range p.ClassStats {
+			if c.Bugs > 0 {
+				classes = append(classes, newHotspot(c.Class, c.Bugs, c.Size))
+			}
+		}
+	}
+	return Hotspots{
+		PackagesByBugs:    topByBugs(packages, n),
+		PackagesByDensity: topByDensity(packages, n),
+		ClassesByBugs:     topByBugs(classes, n),
+		ClassesByDensity:  topByDensity(classes, n),
+	}
+}
+
+func newHotspot(name string, bugs, size int) Hotspot {
+	h := Hotspot{Name: name, Bugs: bugs, Size: size}
+	if size > 0 {
+		h.Density = float64(bugs) * 1000 / float64(size)
+	}
+	return h
+}
+
+func topByBugs(hotspots []Hotspot, n int) []Hotspot {
+	result := append([]Hotspot{}, hotspots...)
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Bugs != result[j].Bugs {
+			return result[i].Bugs > result[j].Bugs
+		}
+		return result[i].Name < result[j].Name
+	})
+	return top(result, n)
+}
+
+func topByDensity(hotspots []Hotspot, n int) []Hotspot {
+	result := make([]Hotspot, 0, len(hotspots))
+	for _, h := range hotspots {
+		if h.Size > 0 {
+			result = append(result, h)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Density != result[j].Density {
+			return result[i].Density > result[j].Density
+		}
+		return result[i].Name < result[j].Name
+	})
+	return top(result, n)
+}
+
+func top(hotspots []Hotspot, n int) []Hotspot {
+	if n > 0 && len(hotspots) > n {
+		return hotspots[:n]
+	}
+	return hotspots
+}
@@ -0,0 +1,323 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+// HTMLMimeType is the mime type of the report written by WriteHTML
+const HTMLMimeType = "text/html"
+
+// CWEURL returns the URL describing the Common Weakness Enumeration entry cweid
+func CWEURL(cweid int) string {
+	return fmt.Sprintf("https://cwe.mitre.org/data/definitions/%d.html", cweid)
+}
+
+// WriteHTML writes a single, self-contained HTML page describing every bug in bugCollection. Bugs are grouped by
+// category, package and class and can be filtered by priority and rank in the browser.
+func WriteHTML(w io.Writer, bugCollection findbugs.BugCollection, title string) error {
+	return htmlTemplate.Execute(w, newHTMLReport(bugCollection, title))
+}
+
+type htmlReport struct {
+	Title       string
+	Version     string
+	Summary     findbugs.FindBugsSummary
+	Errors      findbugs.Errors
+	Total       int
+	Priorities  []htmlPriority
+	Ranks       []htmlRankBucket
+	Categories  []*htmlCategory
+	Hotspots    Hotspots
+	Performance Performance
+	Patterns    []htmlPattern
+}
+
+// htmlHotspots is the number of packages and classes listed in each ranking of hotspots
+const htmlHotspots = 10
+
+// htmlDetectors is the number of the slowest detectors listed
+const htmlDetectors = 10
+
+type htmlPriority struct {
+	Priority int
+	Name     string
+	Count    int
+}
+
+type htmlRankBucket struct {
+	Name  string
+	Count int
+}
+
+type htmlCategory struct {
+	Name        string
+	Description string
+	Count       int
+	Packages    []*htmlPackage
+}
+
+type htmlPackage struct {
+	Name    string
+	Count   int
+	Classes []*htmlClass
+}
+
+type htmlClass struct {
+	Name string
+	Bugs []htmlBug
+}
+
+type htmlBug struct {
+	Finding
+	PriorityValue int
+	RankBucket    string
+}
+
+type htmlPattern struct {
+	Type             string
+	ShortDescription string
+	Details          template.HTML
+	Cweid            int
+}
+
+func newHTMLReport(bugCollection findbugs.BugCollection, title string) htmlReport {
+	r := htmlReport{
+		Title:       title,
+		Version:     bugCollection.Version,
+		Summary:     bugCollection.FindBugsSummary,
+		Errors:      bugCollection.Errors,
+		Total:       len(bugCollection.BugInstance),
+		Hotspots:    FindHotspots(bugCollection, htmlHotspots),
+		Performance: NewPerformance(bugCollection, htmlDetectors),
+	}
+	descriptions := make(map[string]string)
+	for _, c := range bugCollection.BugCategory {
+		descriptions[c.Category] = c.Description
+		if c.Description == "" {
+			descriptions[c.Category] = c.Message
+		}
+	}
+	priorities := make(map[int]int)
+	ranks := make(map[string]int)
+	categories := make(map[string]*htmlCategory)
+	packages := make(map[string]*htmlPackage)
+	classes := make(map[string]*htmlClass)
+	for _, b := range bugCollection.BugInstance {
+		priorities[b.Priority]++
+		ranks[findbugs.RankBucket(b.Rank)]++
+		category, ok := categories[b.Category]
+		if !ok {
+			category = &htmlCategory{Name: b.Category, Description: descriptions[b.Category]}
+			categories[b.Category] = category
+			r.Categories = append(r.Categories, category)
+		}
+		category.Count++
+		packageKey := b.Category + "/" + b.Package()
+		pkg, ok := packages[packageKey]
+		if !ok {
+			pkg = &htmlPackage{Name: b.Package()}
+			packages[packageKey] = pkg
+			category.Packages = append(category.Packages, pkg)
+		}
+		pkg.Count++
+		classKey := b.Category + "/" + b.Class.ClassName
+		class, ok := classes[classKey]
+		if !ok {
+			class = &htmlClass{Name: b.Class.ClassName}
+			classes[classKey] = class
+			pkg.Classes = append(pkg.Classes, class)
+		}
+		class.Bugs = append(class.Bugs, htmlBug{
+			Finding:       NewFinding(b),
+			PriorityValue: b.Priority,
+			RankBucket:    findbugs.RankBucket(b.Rank),
+		})
+	}
+
+	for p := findbugs.PriorityHigh; p <= findbugs.PriorityIgnore; p++ {
+		if priorities[p] > 0 {
+			r.Priorities = append(r.Priorities, htmlPriority{Priority: p, Name: findbugs.PriorityName(p), Count: priorities[p]})
+		}
+	}
+	for _, bucket := range findbugs.RankBuckets {
+		r.Ranks = append(r.Ranks, htmlRankBucket{Name: bucket, Count: ranks[bucket]})
+	}
+	sort.Slice(r.Categories, func(i, j int) bool { return r.Categories[i].Name < r.Categories[j].Name })
+	for _, c := range r.Categories {
+		sort.Slice(c.Packages, func(i, j int) bool { return c.Packages[i].Name < c.Packages[j].Name })
+		for _, p := range c.Packages {
+			sort.Slice(p.Classes, func(i, j int) bool { return p.Classes[i].Name < p.Classes[j].Name })
+			for _, class := range p.Classes {
+				sort.SliceStable(class.Bugs, func(i, j int) bool { return class.Bugs[i].Line < class.Bugs[j].Line })
+			}
+		}
+	}
+	for _, p := range bugCollection.BugPattern {
+		r.Patterns = append(r.Patterns, htmlPattern{
+			Type:             p.Type,
+			ShortDescription: p.ShortDescription,
+			Details:          SanitizeHTML(p.Details),
+			Cweid:            p.Cweid,
+		})
+	}
+	sort.Slice(r.Patterns, func(i, j int) bool { return r.Patterns[i].Type < r.Patterns[j].Type })
+	return r
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"cweURL": CWEURL,
+	"dict":   dict,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292e; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.3em; border-bottom: 1px solid #e1e4e8; padding-bottom: .3em; margin-top: 2em; }
+h3 { font-size: 1.1em; margin-bottom: .3em; }
+h4 { font-size: 1em; margin: .8em 0 .3em; font-family: monospace; }
+table { border-collapse: collapse; }
+table.ranks { margin-top: .5em; }
+td, th { padding: .3em .8em; border: 1px solid #e1e4e8; text-align: left; vertical-align: top; }
+.count { color: #6a737d; font-weight: normal; }
+.priority-1 { color: #cb2431; }
+.priority-2 { color: #e36209; }
+.priority-3 { color: #6f42c1; }
+.priority-4, .priority-5 { color: #6a737d; }
+#filters { background: #f6f8fa; padding: .8em; margin: 1em 0; }
+#filters label { margin-right: 1em; }
+.pattern { margin-bottom: 1.5em; }
+.hidden { display: none; }
+.incomplete { background: #fff5b1; padding: .8em; margin: 1em 0; }
+.hotspots { display: flex; flex-wrap: wrap; gap: 1em; align-items: flex-start; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Total}} bugs in {{.Summary.TotalClasses}} classes{{if .Version}}, analyzed by SpotBugs {{.Version}}{{end}}{{if .Summary.JavaVersion}} on Java {{.Summary.JavaVersion}}{{end}}.</p>
+{{with .Errors}}{{if or .MissingClasses .Errors .MissingClass .AnalysisError}}
+<div class="incomplete">
+<strong>The analysis is incomplete</strong>, bugs may be missing: {{.MissingClasses}} classes were missing and there were {{.Errors}} errors.
+{{if .MissingClass}}<details><summary>Missing classes</summary><ul>{{range .MissingClass}}<li>{{.}}</li>{{end}}</ul></details>{{end}}
+{{if .AnalysisError}}<details><summary>Errors</summary><ul>{{range .AnalysisError}}<li>{{.Message}}{{if .Exception}}: {{.Exception}}{{end}}</li>{{end}}</ul></details>{{end}}
+</div>
+{{end}}{{end}}
+<table>
+<tr>{{range .Priorities}}<th class="priority-{{.Priority}}">{{.Name}}</th>{{end}}</tr>
+<tr>{{range .Priorities}}<td>{{.Count}}</td>{{end}}</tr>
+</table>
+<table class="ranks">
+<tr>{{range .Ranks}}<th>{{.Name}}</th>{{end}}</tr>
+<tr>{{range .Ranks}}<td>{{.Count}}</td>{{end}}</tr>
+</table>
+
+<div id="filters">
+<strong>Priority:</strong>
+{{range .Priorities}}<label><input type="checkbox" class="priority-filter" value="{{.Priority}}" checked> {{.Name}}</label>
+{{end}}
+<label><strong>Maximum rank:</strong> <input type="range" id="rank-filter" min="1" max="20" value="20"> <span id="rank-value">20</span></label>
+<span id="visible-count"></span>
+</div>
+
+{{with .Hotspots}}{{if or .PackagesByBugs .ClassesByBugs}}
+<h2>Hotspots</h2>
+<div class="hotspots">
+{{template "hotspots" dict "Title" "Packages by bugs" "Hotspots" .PackagesByBugs}}
+{{template "hotspots" dict "Title" "Packages by bugs per KLOC" "Hotspots" .PackagesByDensity}}
+{{template "hotspots" dict "Title" "Classes by bugs" "Hotspots" .ClassesByBugs}}
+{{template "hotspots" dict "Title" "Classes by bugs per KLOC" "Hotspots" .ClassesByDensity}}
+</div>
+{{end}}{{end}}
+
+{{range .Categories}}
+<section class="group category">
+<h2>{{.Name}}{{if .Description}} &ndash; {{.Description}}{{end}} <span class="count">({{.Count}})</span></h2>
+{{range .Packages}}
+<section class="group package">
+<h3>{{if .Name}}{{.Name}}{{else}}(default package){{end}} <span class="count">({{.Count}})</span></h3>
+{{range .Classes}}
+<section class="group class">
+<h4>{{.Name}}</h4>
+<table>
+<tr><th>Priority</th><th>Rank</th><th>Pattern</th><th>Location</th><th>Message</th></tr>
+{{range .Bugs}}<tr class="bug" data-priority="{{.PriorityValue}}" data-rank="{{.Rank}}">
+<td class="priority-{{.PriorityValue}}">{{.Priority}}</td>
+<td>{{.Rank}}{{if .RankBucket}} ({{.RankBucket}}){{end}}</td>
+<td><a href="#{{.Pattern}}">{{.Pattern}}</a>{{if .CWE}} (<a href="{{cweURL .CWE}}">CWE-{{.CWE}}</a>){{end}}</td>
+<td>{{if .File}}{{.File}}{{else}}{{.Class}}{{end}}{{if .Line}}:{{.Line}}{{end}}</td>
+<td>{{.Message}}</td>
+</tr>
+{{end}}</table>
+</section>
+{{end}}</section>
+{{end}}</section>
+{{end}}
+
+{{with .Performance}}
+<h2>Analysis</h2>
+<p>SpotBugs took {{printf "%.1f" .ClockSeconds}}s ({{printf "%.1f" .CPUSeconds}}s CPU, {{printf "%.1f" .GCSeconds}}s garbage collection) and up to {{printf "%.0f" .PeakMBytes}} MB of memory.</p>
+{{if .SlowestDetectors}}<table>
+<tr><th>Slowest detectors</th><th>Total ms</th><th>Invocations</th><th>Average &micro;s</th><th>Maximum &micro;s</th></tr>
+{{range .SlowestDetectors}}<tr><td>{{.Name}}</td><td>{{.TotalMilliseconds}}</td><td>{{.Invocations}}</td><td>{{.AvgMicroseconds}}</td><td>{{.MaxMicroseconds}}</td></tr>
+{{end}}</table>{{end}}
+{{end}}
+
+{{if .Patterns}}
+<h2>Bug patterns</h2>
+{{range .Patterns}}
+<div class="pattern" id="{{.Type}}">
+<h3>{{.Type}}: {{.ShortDescription}}</h3>
+{{if .Cweid}}<p><a href="{{cweURL .Cweid}}">CWE-{{.Cweid}}</a></p>{{end}}
+{{.Details}}
+</div>
+{{end}}
+{{end}}
+
+<script>
+(function () {
+  var rank = document.getElementById("rank-filter");
+  function update() {
+    var priorities = {};
+    document.querySelectorAll(".priority-filter").forEach(function (c) { priorities[c.value] = c.checked; });
+    document.getElementById("rank-value").textContent = rank.value;
+    var visible = 0;
+    document.querySelectorAll("tr.bug").forEach(function (row) {
+      var show = priorities[row.dataset.priority] && Number(row.dataset.rank || 20) <= Number(rank.value);
+      row.classList.toggle("hidden", !show);
+      if (show) { visible++; }
+    });
+    document.querySelectorAll("section.group").forEach(function (group) {
+      group.classList.toggle("hidden", group.querySelector("tr.bug:not(.hidden)") === null);
+    });
+    document.getElementById("visible-count").textContent = "Showing " + visible + " of {{.Total}} bugs";
+  }
+  document.querySelectorAll(".priority-filter").forEach(function (c) { c.addEventListener("change", update); });
+  rank.addEventListener("input", update);
+  update();
+})();
+</script>
+</body>
+</html>
+{{define "hotspots"}}{{if .Hotspots}}<table>
+<tr><th>{{.Title}}</th><th>Bugs</th><th>Size</th><th>Bugs/KLOC</th></tr>
+{{range .Hotspots}}<tr><td>{{if .Name}}{{.Name}}{{else}}(default package){{end}}</td><td>{{.Bugs}}</td><td>{{.Size}}</td><td>{{printf "%.1f" .Density}}</td></tr>
+{{end}}</table>{{end}}{{end}}
+`))
+
+// dict creates a map from its arguments, which are pairs of keys and values, to pass several values to a template
+func dict(values ...interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+	for i := 0; i+1 < len(values); i += 2 {
+		if key, ok := values[i].(string); ok {
+			result[key] = values[i+1]
+		}
+	}
+	return result
+}
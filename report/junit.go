@@ -0,0 +1,152 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+const (
+	// JUnitByPackage groups the testcases of WriteJUnit in a testsuite per package
+	JUnitByPackage = "package"
+	// JUnitByCategory groups the testcases of WriteJUnit in a testsuite per bug category
+	JUnitByCategory = "category"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr,omitempty"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure"`
+	Skipped   *junitSkipped `xml:"skipped"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// WriteJUnit writes bugCollection as JUnit XML, so that tools rendering test results show the bugs along with the unit
+// tests. Every bug is a failing testcase, except for bugs of ignored priority which are skipped. The testcases are
+// grouped in a testsuite per package or per category, depending on groupBy which is JUnitByPackage or JUnitByCategory.
+func WriteJUnit(w io.Writer, bugCollection findbugs.BugCollection, groupBy string) error {
+	if groupBy != JUnitByPackage && groupBy != JUnitByCategory {
+		return fmt.Errorf("unknown JUnit grouping %s, must be %s or %s", groupBy, JUnitByPackage, JUnitByCategory)
+	}
+	result := junitTestSuites{Name: "SpotBugs"}
+	suites := make(map[string]*junitTestSuite)
+	var names []string
+	for _, b := range bugCollection.BugInstance {
+		name := b.Category
+		if groupBy == JUnitByPackage {
+			name = b.Package()
+			if name == "" {
+				name = "(default package)"
+			}
+		}
+		suite, ok := suites[name]
+		if !ok {
+			suite = &junitTestSuite{Name: name}
+			suites[name] = suite
+			names = append(names, name)
+		}
+		testCase := newJUnitTestCase(b)
+		suite.Tests++
+		if testCase.Skipped != nil {
+			suite.Skipped++
+		} else {
+			suite.Failures++
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		suite := suites[name]
+		result.Tests += suite.Tests
+		result.Failures += suite.Failures
+		result.Skipped += suite.Skipped
+		result.Suites = append(result.Suites, *suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func newJUnitTestCase(b findbugs.BugInstance) junitTestCase {
+	f := NewFinding(b)
+	name := f.Pattern
+	if f.Method != "" {
+		name = fmt.Sprintf("%s in %s", name, f.Method)
+	}
+	if f.Line > 0 {
+		name = fmt.Sprintf("%s at line %d", name, f.Line)
+	}
+	testCase := junitTestCase{
+		ClassName: f.Class,
+		Name:      name,
+		File:      f.File,
+		Line:      f.Line,
+	}
+	if b.Priority == findbugs.PriorityIgnore {
+		testCase.Skipped = &junitSkipped{Message: f.Message}
+		return testCase
+	}
+	details := []string{
+		f.Message,
+		"",
+		fmt.Sprintf("Category: %s", f.Category),
+		fmt.Sprintf("Priority: %s", f.Priority),
+		fmt.Sprintf("Rank: %d", f.Rank),
+	}
+	if f.CWE > 0 {
+		details = append(details, fmt.Sprintf("CWE: %s", CWEURL(f.CWE)))
+	}
+	if f.File != "" {
+		location := f.File
+		if f.Line > 0 {
+			location = fmt.Sprintf("%s:%d", location, f.Line)
+		}
+		details = append(details, fmt.Sprintf("Location: %s", location))
+	}
+	testCase.Failure = &junitFailure{
+		Message: f.Message,
+		Type:    f.Pattern,
+		Text:    strings.Join(details, "\n"),
+	}
+	return testCase
+}
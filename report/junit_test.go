@@ -0,0 +1,193 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+// parsedTestSuites is the JUnit XML read by test report tools, decoded independently of the types WriteJUnit encodes
+type parsedTestSuites struct {
+	XMLName  xml.Name `xml:"testsuites"`
+	Tests    int      `xml:"tests,attr"`
+	Failures int      `xml:"failures,attr"`
+	Skipped  int      `xml:"skipped,attr"`
+	Suites   []struct {
+		Name      string `xml:"name,attr"`
+		Tests     int    `xml:"tests,attr"`
+		Failures  int    `xml:"failures,attr"`
+		Skipped   int    `xml:"skipped,attr"`
+		TestCases []struct {
+			ClassName string `xml:"classname,attr"`
+			Name      string `xml:"name,attr"`
+			File      string `xml:"file,attr"`
+			Line      int    `xml:"line,attr"`
+			Failure   *struct {
+				Message string `xml:"message,attr"`
+				Type    string `xml:"type,attr"`
+				Text    string `xml:",chardata"`
+			} `xml:"failure"`
+			Skipped *struct {
+				Message string `xml:"message,attr"`
+			} `xml:"skipped"`
+		} `xml:"testcase"`
+	} `xml:"testsuite"`
+}
+
+func TestWriteJUnit(t *testing.T) {
+	bugCollection := findbugs.BugCollection{
+		BugInstance: []findbugs.BugInstance{
+			{
+				Type:        "NP_NULL_ON_SOME_PATH",
+				Category:    "CORRECTNESS",
+				Priority:    findbugs.PriorityHigh,
+				Rank:        3,
+				Cweid:       476,
+				LongMessage: `Possible null pointer dereference of "row" in Dao.find() <a & b> ]]>`,
+				Class:       findbugs.Class{ClassName: "com.example.Dao"},
+				Method:      findbugs.Method{Name: "find"},
+				SourceLine:  findbugs.SourceLine{SourcePath: "com/example/Dao.java", Start: 12},
+			},
+			{
+				Type:         "DM_DEFAULT_ENCODING",
+				Category:     "I18N",
+				Priority:     findbugs.PriorityNormal,
+				Rank:         19,
+				ShortMessage: "Reliance on default encoding",
+				Class:        findbugs.Class{ClassName: "com.example.web.Handler"},
+			},
+			{
+				Type:        "URF_UNREAD_FIELD",
+				Category:    "PERFORMANCE",
+				Priority:    findbugs.PriorityIgnore,
+				LongMessage: "Unread field",
+				Class:       findbugs.Class{ClassName: "Main"},
+			},
+		},
+	}
+	tests := []struct {
+		groupBy string
+		// suites are the names of the testsuites with their number of tests, failures and skipped tests
+		suites map[string][3]int
+	}{
+		{
+			groupBy: JUnitByPackage,
+			suites: map[string][3]int{
+				"(default package)": {1, 0, 1},
+				"com.example":       {1, 1, 0},
+				"com.example.web":   {1, 1, 0},
+			},
+		},
+		{
+			groupBy: JUnitByCategory,
+			suites: map[string][3]int{
+				"CORRECTNESS": {1, 1, 0},
+				"I18N":        {1, 1, 0},
+				"PERFORMANCE": {1, 0, 1},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.groupBy, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteJUnit(&buf, bugCollection, test.groupBy); err != nil {
+				t.Fatal(err)
+			}
+			var parsed parsedTestSuites
+			if err := xml.Unmarshal(buf.Bytes(), &parsed); err != nil {
+				t.Fatalf("got invalid XML: %v\n%s", err, buf.String())
+			}
+			if parsed.Tests != 3 || parsed.Failures != 2 || parsed.Skipped != 1 {
+				t.Errorf("got %d tests, %d failures and %d skipped, want 3, 2 and 1", parsed.Tests, parsed.Failures,
+					parsed.Skipped)
+			}
+			suites := make(map[string][3]int)
+			testCases := make(map[string]int)
+			for _, suite := range parsed.Suites {
+				suites[suite.Name] = [3]int{suite.Tests, suite.Failures, suite.Skipped}
+				failures, skipped := 0, 0
+				for _, testCase := range suite.TestCases {
+					testCases[testCase.ClassName]++
+					if testCase.Failure != nil {
+						failures++
+					}
+					if testCase.Skipped != nil {
+						skipped++
+					}
+				}
+				if len(suite.TestCases) != suite.Tests || failures != suite.Failures || skipped != suite.Skipped {
+					t.Errorf("testsuite %s has %d testcases, %d failures and %d skipped, which its counts don't match",
+						suite.Name, len(suite.TestCases), failures, skipped)
+				}
+			}
+			if !reflect.DeepEqual(suites, test.suites) {
+				t.Errorf("got testsuites %v, want %v", suites, test.suites)
+			}
+			for _, className := range []string{"com.example.Dao", "com.example.web.Handler", "Main"} {
+				if testCases[className] != 1 {
+					t.Errorf("got %d testcases of %s, want 1", testCases[className], className)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteJUnitTestCase(t *testing.T) {
+	bug := findbugs.BugInstance{
+		Type:        "NP_NULL_ON_SOME_PATH",
+		Category:    "CORRECTNESS",
+		Priority:    findbugs.PriorityHigh,
+		Rank:        3,
+		Cweid:       476,
+		LongMessage: `Possible null pointer dereference of "row" <a & b> ]]>`,
+		Class:       findbugs.Class{ClassName: "com.example.Dao"},
+		Method:      findbugs.Method{Name: "find"},
+		SourceLine:  findbugs.SourceLine{SourcePath: "com/example/Dao.java", Start: 12},
+	}
+	bugCollection := findbugs.BugCollection{BugInstance: []findbugs.BugInstance{bug}}
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, bugCollection, JUnitByPackage); err != nil {
+		t.Fatal(err)
+	}
+	var parsed parsedTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("got invalid XML: %v\n%s", err, buf.String())
+	}
+	if len(parsed.Suites) != 1 || len(parsed.Suites[0].TestCases) != 1 {
+		t.Fatalf("got\n%s\nwant a single testcase", buf.String())
+	}
+	testCase := parsed.Suites[0].TestCases[0]
+	if testCase.ClassName != "com.example.Dao" || testCase.Name != "NP_NULL_ON_SOME_PATH in find at line 12" ||
+		testCase.File != "com/example/Dao.java" || testCase.Line != 12 {
+		t.Errorf("got testcase %s %q in %s:%d", testCase.ClassName, testCase.Name, testCase.File, testCase.Line)
+	}
+	if testCase.Failure == nil {
+		t.Fatal("got no failure")
+	}
+	if testCase.Failure.Message != bug.LongMessage || testCase.Failure.Type != bug.Type {
+		t.Errorf("got failure %q of type %s, want %q of type %s", testCase.Failure.Message, testCase.Failure.Type,
+			bug.LongMessage, bug.Type)
+	}
+	wantText := strings.Join([]string{
+		bug.LongMessage,
+		"",
+		"Category: CORRECTNESS",
+		"Priority: high",
+		"Rank: 3",
+		"CWE: https://cwe.mitre.org/data/definitions/476.html",
+		"Location: com/example/Dao.java:12",
+	}, "\n")
+	if testCase.Failure.Text != wantText {
+		t.Errorf("got failure text\n%s\nwant\n%s", testCase.Failure.Text, wantText)
+	}
+}
+
+func TestWriteJUnitUnknownGrouping(t *testing.T) {
+	if err := WriteJUnit(&bytes.Buffer{}, findbugs.BugCollection{}, "module"); err == nil {
+		t.Error("got no error for an unknown grouping")
+	}
+}
@@ -0,0 +1,346 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+// MarkdownOptions configures WriteMarkdown
+type MarkdownOptions struct {
+	// Title is rendered as the top level heading, omitted if empty
+	Title string
+	// Diff adds sections listing the bugs introduced and fixed, compared to a base report
+	Diff *findbugs.Diff
+	// MaxBugs limits the number of bugs listed in the new and fixed sections, 0 for no limit
+	MaxBugs int
+}
+
+// WriteMarkdown writes a summary of bugCollection as GitHub flavoured Markdown: a table of totals, a breakdown per
+// category, the new and fixed bugs if options.Diff is set, and the description of every bug pattern found in
+// collapsible sections. It is meant to be posted to pull requests, release notes or chat.
+func WriteMarkdown(w io.Writer, bugCollection findbugs.BugCollection, options MarkdownOptions) error {
+	mw := &markdownWriter{w: w}
+	if options.Title != "" {
+		mw.printf("# %s\n\n", options.Title)
+	}
+
+	priorities := priorityColumns(bugCollection.BugInstance)
+	counts := make(map[int]int)
+	categories := make(map[string]map[int]int)
+	for _, b := range bugCollection.BugInstance {
+		counts[b.Priority]++
+		if categories[b.Category] == nil {
+			categories[b.Category] = make(map[int]int)
+		}
+		categories[b.Category][b.Priority]++
+	}
+
+	mw.printf("**%d** bugs in %d classes", len(bugCollection.BugInstance), bugCollection.FindBugsSummary.TotalClasses)
+	if options.Diff != nil {
+		mw.printf(", **%d** new and **%d** fixed", len(options.Diff.New), len(options.Diff.Fixed))
+	}
+	mw.printf(".\n\n")
+
+	if len(bugCollection.BugInstance) > 0 {
+		mw.printf("| Category |")
+		for _, p := range priorities {
+			mw.printf(" %s |", strings.Title(findbugs.PriorityName(p)))
+		}
+		mw.printf(" Total |\n|---|")
+		for range priorities {
+			mw.printf("--:|")
+		}
+		mw.printf("--:|\n")
+		names := make([]string, 0, len(categories))
+		for name := range categories {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			mw.printf("| %s |", escapeMarkdownCell(name))
+			total := 0
+			for _, p := range priorities {
+				mw.printf(" %d |", categories[name][p])
+				total += categories[name][p]
+			}
+			mw.printf(" %d |\n", total)
+		}
+		mw.printf("| **Total** |")
+		for _, p := range priorities {
+			mw.printf(" **%d** |", counts[p])
+		}
+		mw.printf(" **%d** |\n\n", len(bugCollection.BugInstance))
+	}
+
+	patterns := []findbugs.BugInstance{}
+	patterns = append(patterns, bugCollection.BugInstance...)
+	if options.Diff != nil {
+		mw.bugSection("New bugs", options.Diff.New, options.MaxBugs)
+		mw.bugSection("Fixed bugs", options.Diff.Fixed, options.MaxBugs)
+		patterns = append(patterns, options.Diff.Fixed...)
+	}
+	mw.patternSection(bugCollection.BugPattern, patterns)
+	return mw.err
+}
+
+// priorityColumns returns the priorities to show, which are high, normal and low plus any other priority found
+func priorityColumns(bugs []findbugs.BugInstance) []int {
+	found := map[int]bool{
+		findbugs.PriorityHigh:   true,
+		findbugs.PriorityNormal: true,
+		findbugs.PriorityLow:    true,
+	}
+	for _, b := range bugs {
+		found[b.Priority] = true
+	}
+	var result []int
+	for p := range found {
+		result = append(result, p)
+	}
+	sort.Ints(result)
+	return result
+}
+
+type markdownWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (mw *markdownWriter) printf(format string, args ...interface{}) {
+	if mw.err == nil {
+		_, mw.err = fmt.Fprintf(mw.w, format, args...)
+	}
+}
+
+func (mw *markdownWriter) bugSection(title string, bugs []findbugs.BugInstance, max int) {
+	mw.printf("## %s (%d)\n\n", title, len(bugs))
+	if len(bugs) == 0 {
+		mw.printf("None.\n\n")
+		return
+	}
+	mw.printf("| Priority | Pattern | Location | Message |\n|---|---|---|---|\n")
+	for i, b := range bugs {
+		if max > 0 && i == max {
+			mw.printf("\n_and %d more_\n", len(bugs)-max)
+			break
+		}
+		f := NewFinding(b)
+		location := f.Class
+		if f.File != "" {
+			location = f.File
+		}
+		if f.Line > 0 {
+			location = fmt.Sprintf("%s:%d", location, f.Line)
+		}
+		mw.printf("| %s | `%s` | `%s` | %s |\n", f.Priority, f.Pattern, location, escapeMarkdownCell(f.Message))
+	}
+	mw.printf("\n")
+}
+
+// patternSection describes the patterns of bugs in collapsible sections
+func (mw *markdownWriter) patternSection(patterns []findbugs.BugPattern, bugs []findbugs.BugInstance) {
+	counts := make(map[string]int)
+	for _, b := range bugs {
+		counts[b.Type]++
+	}
+	var found []findbugs.BugPattern
+	for _, p := range patterns {
+		if counts[p.Type] > 0 {
+			found = append(found, p)
+		}
+	}
+	if len(found) == 0 {
+		return
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Type < found[j].Type })
+	mw.printf("## Bug patterns\n\n")
+	for _, p := range found {
+		mw.printf("<details>\n<summary><code>%s</code> %s</summary>\n\n", p.Type, escapeMarkdown(p.ShortDescription))
+		if p.Cweid > 0 {
+			mw.printf("[CWE-%d](%s)\n\n", p.Cweid, CWEURL(p.Cweid))
+		}
+		mw.printf("%s\n\n</details>\n\n", HTMLToMarkdown(p.Details))
+	}
+}
+
+var (
+	markdownEscaper     = strings.NewReplacer(`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", "&lt;", ">", "&gt;")
+	markdownCellEscaper = strings.NewReplacer("|", `\|`, "\n", " ", "\r", "")
+	whitespace          = regexp.MustCompile(`\s+`)
+	blankLines          = regexp.MustCompile(`\n{3,}`)
+)
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+func escapeMarkdownCell(s string) string {
+	return markdownCellEscaper.Replace(escapeMarkdown(s))
+}
+
+// HTMLToMarkdown converts the HTML fragment s, as found in BugPattern.Details, to Markdown. Formatting without a
+// Markdown equivalent is dropped, keeping its text.
+func HTMLToMarkdown(s string) string {
+	var buf strings.Builder
+	var lists []string
+	var counters []int
+	// markers are the list markers of the current items of lists, nested items are indented by their width
+	var markers []string
+	var links []string
+	pre := false
+	newline := func(n int) {
+		current := buf.String()
+		trailing := len(current) - len(strings.TrimRight(current, "\n"))
+		for ; trailing < n && buf.Len() > 0; trailing++ {
+			buf.WriteString("\n")
+		}
+	}
+	dropping := ""
+	for _, t := range tokenizeHTML(s) {
+		if dropping != "" {
+			if t.kind == endTag && t.name == dropping {
+				dropping = ""
+			}
+			continue
+		}
+		switch t.kind {
+		case text:
+			if pre {
+				// As in HTML, a newline right after <pre> isn't part of its content
+				if strings.HasSuffix(buf.String(), "```\n") {
+					t.text = strings.TrimPrefix(t.text, "\n")
+				}
+				buf.WriteString(t.text)
+				continue
+			}
+			content := whitespace.ReplaceAllString(t.text, " ")
+			if strings.HasSuffix(buf.String(), "\n") || buf.Len() == 0 {
+				content = strings.TrimLeft(content, " ")
+			}
+			buf.WriteString(escapeMarkdown(content))
+		case startTag:
+			switch t.name {
+			case "p", "div", "table", "dl", "blockquote":
+				newline(2)
+			case "br":
+				buf.WriteString("  \n")
+			case "tr", "dt", "dd":
+				newline(1)
+			case "td", "th":
+				buf.WriteString(" ")
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				newline(2)
+				buf.WriteString("**")
+			case "b", "strong":
+				buf.WriteString("**")
+			case "i", "em":
+				buf.WriteString("_")
+			case "code", "tt":
+				if !pre {
+					buf.WriteString("`")
+				}
+			case "pre":
+				newline(2)
+				buf.WriteString("```\n")
+				pre = true
+			case "ul", "ol":
+				newline(1)
+				lists = append(lists, t.name)
+				counters = append(counters, 0)
+				markers = append(markers, "")
+			case "li":
+				newline(1)
+				marker := "- "
+				if len(lists) > 0 {
+					last := len(lists) - 1
+					for _, m := range markers[:last] {
+						buf.WriteString(strings.Repeat(" ", len(m)))
+					}
+					if lists[last] == "ol" {
+						counters[last]++
+						marker = fmt.Sprintf("%d. ", counters[last])
+					}
+					markers[last] = marker
+				}
+				buf.WriteString(marker)
+			case "a":
+				href := ""
+				for _, attr := range t.attrs {
+					if attr.name == "href" && safeURL(attr.value) {
+						href = attr.value
+					}
+				}
+				links = append(links, href)
+				if href != "" {
+					buf.WriteString("[")
+				}
+			default:
+				if droppedElements[t.name] && !t.selfClosing {
+					dropping = t.name
+				}
+			}
+		case endTag:
+			switch t.name {
+			case "p", "div", "table", "dl", "blockquote":
+				newline(2)
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				buf.WriteString("**")
+				newline(2)
+			case "b", "strong":
+				buf.WriteString("**")
+			case "i", "em":
+				buf.WriteString("_")
+			case "code", "tt":
+				if !pre {
+					buf.WriteString("`")
+				}
+			case "pre":
+				if pre {
+					newline(1)
+					buf.WriteString("```")
+					newline(2)
+					pre = false
+				}
+			case "td", "th":
+				buf.WriteString(" |")
+			case "ul", "ol":
+				if len(lists) > 0 {
+					lists = lists[:len(lists)-1]
+					counters = counters[:len(counters)-1]
+					markers = markers[:len(markers)-1]
+				}
+				// A nested list ends with its item in the enclosing list
+				if len(lists) > 0 {
+					newline(1)
+				} else {
+					newline(2)
+				}
+			case "a":
+				if len(links) > 0 {
+					href := links[len(links)-1]
+					links = links[:len(links)-1]
+					if href != "" {
+						buf.WriteString("](" + href + ")")
+					}
+				}
+			}
+		}
+	}
+	if pre {
+		newline(1)
+		buf.WriteString("```")
+	}
+	result := blankLines.ReplaceAllString(buf.String(), "\n\n")
+	lines := strings.Split(result, "\n")
+	for i, line := range lines {
+		if !strings.HasSuffix(line, "  ") {
+			lines[i] = strings.TrimRight(line, " ")
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
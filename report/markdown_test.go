@@ -0,0 +1,208 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	bugs := []findbugs.BugInstance{
+		{
+			Type:        "NP_NULL_ON_SOME_PATH",
+			Category:    "CORRECTNESS",
+			Priority:    findbugs.PriorityHigh,
+			LongMessage: "Possible null | pointer *dereference*\nof `row_id` in [Dao]",
+			Class:       findbugs.Class{ClassName: "com.example.Dao"},
+			SourceLine:  findbugs.SourceLine{SourcePath: "com/example/Dao.java", Start: 12},
+		},
+		{
+			Type:         "SQL_NONCONSTANT_STRING_PASSED_TO_EXECUTE",
+			Category:     "SECURITY",
+			Priority:     findbugs.PriorityNormal,
+			ShortMessage: "<script>alert(1)</script>",
+			Class:        findbugs.Class{ClassName: "com.example.Query"},
+		},
+		{
+			Type:        "URF_UNREAD_FIELD",
+			Category:    "PERFORMANCE",
+			Priority:    findbugs.PriorityExperimental,
+			LongMessage: "Unread field",
+			Class:       findbugs.Class{ClassName: "com.example.Row"},
+		},
+	}
+	bugCollection := findbugs.BugCollection{
+		BugInstance:     bugs,
+		FindBugsSummary: findbugs.FindBugsSummary{TotalClasses: 3},
+		BugPattern: []findbugs.BugPattern{
+			{
+				Type:             "NP_NULL_ON_SOME_PATH",
+				ShortDescription: "Possible null *pointer* <dereference>",
+				Cweid:            476,
+				Details:          `<p>A <b>null</b> value is dereferenced.</p>`,
+			},
+			{Type: "UNUSED_PATTERN", ShortDescription: "Not found", Details: "<p>Unused</p>"},
+		},
+	}
+	tests := []struct {
+		name          string
+		bugCollection findbugs.BugCollection
+		options       MarkdownOptions
+		want          string
+	}{
+		{
+			name:          "summary",
+			bugCollection: bugCollection,
+			options:       MarkdownOptions{Title: "SpotBugs"},
+			want: "# SpotBugs\n" +
+				"\n" +
+				"**3** bugs in 3 classes.\n" +
+				"\n" +
+				"| Category | High | Normal | Low | Experimental | Total |\n" +
+				"|---|--:|--:|--:|--:|--:|\n" +
+				"| CORRECTNESS | 1 | 0 | 0 | 0 | 1 |\n" +
+				"| PERFORMANCE | 0 | 0 | 0 | 1 | 1 |\n" +
+				"| SECURITY | 0 | 1 | 0 | 0 | 1 |\n" +
+				"| **Total** | **1** | **1** | **0** | **1** | **3** |\n" +
+				"\n" +
+				"## Bug patterns\n" +
+				"\n" +
+				"<details>\n" +
+				"<summary><code>NP_NULL_ON_SOME_PATH</code> Possible null \\*pointer\\* &lt;dereference&gt;</summary>\n" +
+				"\n" +
+				"[CWE-476](https://cwe.mitre.org/data/definitions/476.html)\n" +
+				"\n" +
+				"A **null** value is dereferenced.\n" +
+				"\n" +
+				"</details>\n" +
+				"\n",
+		},
+		{
+			name:          "diff truncated to MaxBugs",
+			bugCollection: bugCollection,
+			options:       MarkdownOptions{Diff: &findbugs.Diff{New: bugs, Fixed: bugs[:1]}, MaxBugs: 2},
+			want: "**3** bugs in 3 classes, **3** new and **1** fixed.\n" +
+				"\n" +
+				"| Category | High | Normal | Low | Experimental | Total |\n" +
+				"|---|--:|--:|--:|--:|--:|\n" +
+				"| CORRECTNESS | 1 | 0 | 0 | 0 | 1 |\n" +
+				"| PERFORMANCE | 0 | 0 | 0 | 1 | 1 |\n" +
+				"| SECURITY | 0 | 1 | 0 | 0 | 1 |\n" +
+				"| **Total** | **1** | **1** | **0** | **1** | **3** |\n" +
+				"\n" +
+				"## New bugs (3)\n" +
+				"\n" +
+				"| Priority | Pattern | Location | Message |\n" +
+				"|---|---|---|---|\n" +
+				"| high | `NP_NULL_ON_SOME_PATH` | `com/example/Dao.java:12` | " +
+				"Possible null \\| pointer \\*dereference\\* of \\`row\\_id\\` in \\[Dao\\] |\n" +
+				"| normal | `SQL_NONCONSTANT_STRING_PASSED_TO_EXECUTE` | `com.example.Query` | " +
+				"&lt;script&gt;alert(1)&lt;/script&gt; |\n" +
+				"\n" +
+				"_and 1 more_\n" +
+				"\n" +
+				"## Fixed bugs (1)\n" +
+				"\n" +
+				"| Priority | Pattern | Location | Message |\n" +
+				"|---|---|---|---|\n" +
+				"| high | `NP_NULL_ON_SOME_PATH` | `com/example/Dao.java:12` | " +
+				"Possible null \\| pointer \\*dereference\\* of \\`row\\_id\\` in \\[Dao\\] |\n" +
+				"\n" +
+				"## Bug patterns\n" +
+				"\n" +
+				"<details>\n" +
+				"<summary><code>NP_NULL_ON_SOME_PATH</code> Possible null \\*pointer\\* &lt;dereference&gt;</summary>\n" +
+				"\n" +
+				"[CWE-476](https://cwe.mitre.org/data/definitions/476.html)\n" +
+				"\n" +
+				"A **null** value is dereferenced.\n" +
+				"\n" +
+				"</details>\n" +
+				"\n",
+		},
+		{
+			name:    "empty diff",
+			options: MarkdownOptions{Diff: &findbugs.Diff{}},
+			want: "**0** bugs in 0 classes, **0** new and **0** fixed.\n" +
+				"\n" +
+				"## New bugs (0)\n" +
+				"\n" +
+				"None.\n" +
+				"\n" +
+				"## Fixed bugs (0)\n" +
+				"\n" +
+				"None.\n" +
+				"\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteMarkdown(&buf, test.bugCollection, test.options); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHTMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "escaped text",
+			html: `<p>Use <code>a_b*c</code> for [x] of List&lt;T&gt; \ here</p>`,
+			want: "Use `a\\_b\\*c` for \\[x\\] of List&lt;T&gt; \\\\ here",
+		},
+		{
+			name: "paragraphs and formatting",
+			html: "<p>First\n   paragraph with <i>emphasis</i>.</p><p>Second<br>line</p><h3>Heading</h3>",
+			want: "First paragraph with _emphasis_.\n\nSecond  \nline\n\n**Heading**",
+		},
+		{
+			name: "nested lists",
+			html: `<ol><li>one<ul><li>nested <b>bold</b></li><li>two</li></ul></li><li>three</li></ol><p>after</p>`,
+			want: "1. one\n   - nested **bold**\n   - two\n2. three\n\nafter",
+		},
+		{
+			name: "pre",
+			html: "<p>Example:</p><pre>\n  if (a &lt; b) {\n    x = *p;\n  }\n</pre><p>after</p>",
+			want: "Example:\n\n```\n  if (a < b) {\n    x = *p;\n  }\n```\n\nafter",
+		},
+		{
+			name: "unterminated pre",
+			html: "<pre>x_1 = 2",
+			want: "```\nx_1 = 2\n```",
+		},
+		{
+			name: "safe links",
+			html: `See <a href="https://cwe.mitre.org/data/definitions/476.html">CWE-476</a> or ` +
+				`<a href="mailto:security@example.com">mail</a>`,
+			want: "See [CWE-476](https://cwe.mitre.org/data/definitions/476.html) or [mail](mailto:security@example.com)",
+		},
+		{
+			name: "unsafe links keep their text",
+			html: `<a href="javascript:alert(1)">click</a> <a href="  JavaScript:alert(1)">here</a> ` +
+				`<a href="data:text/html;base64,PHNjcmlwdD4=">or here</a> <a>anchor</a>`,
+			want: "click here or here anchor",
+		},
+		{
+			name: "dropped elements",
+			html: `before<script>alert("x")</script> after<style>p { display: none }</style>`,
+			want: "before after",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := HTMLToMarkdown(test.html); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package report
+
+import (
+	"sort"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+// Performance is the cost of the analysis which produced a report
+type Performance struct {
+	ClockSeconds float64 `json:"clockSeconds"`
+	CPUSeconds   float64 `json:"cpuSeconds"`
+	GCSeconds    float64 `json:"gcSeconds"`
+	PeakMBytes   float64 `json:"peakMBytes"`
+	// SlowestDetectors are the detectors and analysis engines SpotBugs spent the most time in
+	SlowestDetectors []DetectorProfile `json:"slowestDetectors"`
+}
+
+// DetectorProfile is the time SpotBugs spent in a detector or analysis engine
+type DetectorProfile struct {
+	Name              string `json:"name"`
+	TotalMilliseconds int    `json:"totalMilliseconds"`
+	Invocations       int    `json:"invocations"`
+	// AvgMicroseconds and MaxMicroseconds are per invocation
+	AvgMicroseconds int `json:"avgMicroseconds"`
+	MaxMicroseconds int `json:"maxMicroseconds"`
+}
+
+// NewPerformance returns the cost of the analysis which produced bugCollection, including the n slowest detectors.
+// SpotBugs only profiles detectors if asked to, e.g. with -Dprofiler.report=true, otherwise there are none.
+func NewPerformance(bugCollection findbugs.BugCollection, n int) Performance {
+	summary := bugCollection.FindBugsSummary
+	p := Performance{
+		ClockSeconds:     float64(summary.ClockSeconds),
+		CPUSeconds:       float64(summary.CPUSeconds),
+		GCSeconds:        float64(summary.GCSeconds),
+		PeakMBytes:       float64(summary.PeakMBytes),
+		SlowestDetectors: make([]DetectorProfile, 0),
+	}
+	for _, c := range summary.FindBugsProfile.ClassProfile {
+		p.SlowestDetectors = append(p.SlowestDetectors, DetectorProfile{
+			Name:              c.Name,
+			TotalMilliseconds: c.TotalMilliseconds,
+			Invocations:       c.Invocations,
+			AvgMicroseconds:   c.AvgMicrosecondsPerInvocation,
+			MaxMicroseconds:   c.MaxMicrosecondsPerInvocation,
+		})
+	}
+	sort.SliceStable(p.SlowestDetectors, func(i, j int) bool {
+		return p.SlowestDetectors[i].TotalMilliseconds > p.SlowestDetectors[j].TotalMilliseconds
+	})
+	if n > 0 && len(p.SlowestDetectors) > n {
+		p.SlowestDetectors = p.SlowestDetectors[:n]
+	}
+	return p
+}
@@ -0,0 +1,262 @@
+package report
+
+import (
+	"bytes"
+	"html"
+	"html/template"
+	"net/url"
+	"strings"
+)
+
+// allowedElements are the elements, and their attributes, kept when sanitizing the HTML of bug pattern details
+var allowedElements = map[string][]string{
+	"a":          {"href"},
+	"b":          nil,
+	"blockquote": nil,
+	"br":         nil,
+	"code":       nil,
+	"dd":         nil,
+	"dl":         nil,
+	"dt":         nil,
+	"em":         nil,
+	"h1":         nil,
+	"h2":         nil,
+	"h3":         nil,
+	"h4":         nil,
+	"i":          nil,
+	"li":         nil,
+	"ol":         nil,
+	"p":          nil,
+	"pre":        nil,
+	"strong":     nil,
+	"table":      nil,
+	"tbody":      nil,
+	"td":         nil,
+	"th":         nil,
+	"thead":      nil,
+	"tr":         nil,
+	"tt":         nil,
+	"ul":         nil,
+}
+
+// droppedElements are removed together with their content
+var droppedElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"iframe":   true,
+	"object":   true,
+	"embed":    true,
+	"template": true,
+}
+
+// voidElements never have content or an end tag
+var voidElements = map[string]bool{
+	"br": true,
+}
+
+// SanitizeHTML returns the HTML fragment s, as found in BugPattern.Details, reduced to a safe subset of formatting
+// elements. Every other element is removed, keeping its text unless it is a script or similar.
+func SanitizeHTML(s string) template.HTML {
+	var buf bytes.Buffer
+	var open []string
+	dropping := ""
+	for _, t := range tokenizeHTML(s) {
+		if dropping != "" {
+			// Skip everything up to the end of the dropped element
+			if t.kind == endTag && t.name == dropping {
+				dropping = ""
+			}
+			continue
+		}
+		switch t.kind {
+		case startTag:
+			if droppedElements[t.name] {
+				if !t.selfClosing {
+					dropping = t.name
+				}
+				continue
+			}
+			attrs, ok := allowedElements[t.name]
+			if !ok {
+				continue
+			}
+			buf.WriteString("<" + t.name)
+			for _, attr := range t.attrs {
+				if !contains(attrs, attr.name) || (attr.name == "href" && !safeURL(attr.value)) {
+					continue
+				}
+				buf.WriteString(" " + attr.name + `="` + html.EscapeString(attr.value) + `"`)
+			}
+			if t.name == "a" {
+				buf.WriteString(` rel="noopener noreferrer"`)
+			}
+			buf.WriteString(">")
+			if !voidElements[t.name] && !t.selfClosing {
+				open = append(open, t.name)
+			} else if !voidElements[t.name] {
+				buf.WriteString("</" + t.name + ">")
+			}
+		case endTag:
+			// Close everything opened since the matching start tag, ignoring stray end tags
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == t.name {
+					for j := len(open) - 1; j >= i; j-- {
+						buf.WriteString("</" + open[j] + ">")
+					}
+					open = open[:i]
+					break
+				}
+			}
+		case text:
+			buf.WriteString(html.EscapeString(t.text))
+		}
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		buf.WriteString("</" + open[i] + ">")
+	}
+	return template.HTML(buf.String())
+}
+
+type htmlTokenKind int
+
+const (
+	text htmlTokenKind = iota
+	startTag
+	endTag
+)
+
+type htmlAttr struct {
+	name  string
+	value string
+}
+
+// htmlToken is a token of an HTML fragment. Names are lower case, text and attribute values are unescaped
+type htmlToken struct {
+	kind        htmlTokenKind
+	name        string
+	attrs       []htmlAttr
+	selfClosing bool
+	text        string
+}
+
+// tokenizeHTML splits the HTML fragment s into tags and text. It is lenient like browsers are: a < which doesn't start
+// a tag is text, tags don't need to be balanced and comments are dropped
+func tokenizeHTML(s string) []htmlToken {
+	var tokens []htmlToken
+	var pending strings.Builder
+	flush := func() {
+		if pending.Len() > 0 {
+			tokens = append(tokens, htmlToken{kind: text, text: html.UnescapeString(pending.String())})
+			pending.Reset()
+		}
+	}
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			pending.WriteString(s)
+			break
+		}
+		pending.WriteString(s[:i])
+		s = s[i:]
+		if strings.HasPrefix(s, "<!--") {
+			end := strings.Index(s, "-->")
+			if end < 0 {
+				break
+			}
+			s = s[end+3:]
+			continue
+		}
+		end := strings.IndexByte(s, '>')
+		if end < 0 || !isTagStart(s[1:end]) {
+			pending.WriteByte('<')
+			s = s[1:]
+			continue
+		}
+		flush()
+		tokens = append(tokens, parseTag(s[1:end]))
+		s = s[end+1:]
+	}
+	flush()
+	return tokens
+}
+
+func isTagStart(tag string) bool {
+	tag = strings.TrimPrefix(tag, "/")
+	return len(tag) > 0 && (tag[0] >= 'a' && tag[0] <= 'z' || tag[0] >= 'A' && tag[0] <= 'Z')
+}
+
+// parseTag parses the content of a tag, between < and >
+func parseTag(tag string) htmlToken {
+	t := htmlToken{kind: startTag}
+	if strings.HasPrefix(tag, "/") {
+		t.kind = endTag
+		tag = tag[1:]
+	}
+	if strings.HasSuffix(tag, "/") {
+		t.selfClosing = true
+		tag = tag[:len(tag)-1]
+	}
+	nameEnd := strings.IndexAny(tag, " \t\r\n")
+	if nameEnd < 0 {
+		nameEnd = len(tag)
+	}
+	t.name = strings.ToLower(tag[:nameEnd])
+	rest := tag[nameEnd:]
+	for {
+		rest = strings.TrimLeft(rest, " \t\r\n")
+		if rest == "" {
+			break
+		}
+		nameEnd := strings.IndexAny(rest, "= \t\r\n")
+		if nameEnd < 0 {
+			t.attrs = append(t.attrs, htmlAttr{name: strings.ToLower(rest)})
+			break
+		}
+		attr := htmlAttr{name: strings.ToLower(rest[:nameEnd])}
+		rest = strings.TrimLeft(rest[nameEnd:], " \t\r\n")
+		if strings.HasPrefix(rest, "=") {
+			rest = strings.TrimLeft(rest[1:], " \t\r\n")
+			var value string
+			if len(rest) > 0 && (rest[0] == '"' || rest[0] == '\'') {
+				quote := rest[0]
+				valueEnd := strings.IndexByte(rest[1:], quote)
+				if valueEnd < 0 {
+					value, rest = rest[1:], ""
+				} else {
+					value, rest = rest[1:valueEnd+1], rest[valueEnd+2:]
+				}
+			} else {
+				valueEnd := strings.IndexAny(rest, " \t\r\n")
+				if valueEnd < 0 {
+					valueEnd = len(rest)
+				}
+				value, rest = rest[:valueEnd], rest[valueEnd:]
+			}
+			attr.value = html.UnescapeString(value)
+		}
+		t.attrs = append(t.attrs, attr)
+	}
+	return t
+}
+
+// safeURL only allows links to web pages, in particular no javascript: URLs
+func safeURL(value string) bool {
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
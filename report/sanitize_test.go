@@ -0,0 +1,125 @@
+package report
+
+import "testing"
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "bug pattern details",
+			html: `<p> This method contains a <b>double assignment</b>; e.g. </p><pre>
+  int x;
+  x = x = 17;
+</pre>`,
+			want: `<p> This method contains a <b>double assignment</b>; e.g. </p><pre>
+  int x;
+  x = x = 17;
+</pre>`,
+		},
+		{
+			name: "link",
+			html: `See <a href="https://cwe.mitre.org/data/definitions/476.html" target="_blank">CWE-476</a>`,
+			want: `See <a href="https://cwe.mitre.org/data/definitions/476.html" rel="noopener noreferrer">CWE-476</a>`,
+		},
+		{
+			name: "javascript link",
+			html: `<a href="javascript:alert(1)">click</a>`,
+			want: `<a rel="noopener noreferrer">click</a>`,
+		},
+		{
+			name: "javascript link with spaces and case",
+			html: `<a href="  JavaScript:alert(1)">click</a>`,
+			want: `<a rel="noopener noreferrer">click</a>`,
+		},
+		{
+			name: "data link",
+			html: `<a href="data:text/html;base64,PHNjcmlwdD4=">click</a>`,
+			want: `<a rel="noopener noreferrer">click</a>`,
+		},
+		{
+			name: "script",
+			html: `before<script>alert("x")</script>after`,
+			want: `beforeafter`,
+		},
+		{
+			name: "upper case script",
+			html: `before<SCRIPT type="text/javascript">alert("x")</SCRIPT>after`,
+			want: `beforeafter`,
+		},
+		{
+			name: "style",
+			html: `<style>p { display: none }</style><p>text</p>`,
+			want: `<p>text</p>`,
+		},
+		{
+			name: "event handler",
+			html: `<p onclick="alert(1)" style="color: red">text</p>`,
+			want: `<p>text</p>`,
+		},
+		{
+			name: "unknown element keeps its text",
+			html: `<img src="x" onerror="alert(1)"><span class="x">text</span>`,
+			want: `text`,
+		},
+		{
+			name: "escaped text",
+			html: `if (a &lt; b &amp;&amp; c &gt; d) <code>List&lt;String&gt;</code>`,
+			want: `if (a &lt; b &amp;&amp; c &gt; d) <code>List&lt;String&gt;</code>`,
+		},
+		{
+			name: "less than which isn't a tag",
+			html: `a < b and 1 <2`,
+			want: `a &lt; b and 1 &lt;2`,
+		},
+		{
+			name: "attribute value escaped",
+			html: `<a href='https://example.com/?a=1&amp;b="2"'>x</a>`,
+			want: `<a href="https://example.com/?a=1&amp;b=&#34;2&#34;" rel="noopener noreferrer">x</a>`,
+		},
+		{
+			name: "unclosed elements",
+			html: `<ul><li>one<li>two`,
+			want: `<ul><li>one<li>two</li></li></ul>`,
+		},
+		{
+			name: "stray end tags",
+			html: `</div></p>text</b>`,
+			want: `text`,
+		},
+		{
+			name: "misnested elements",
+			html: `<b><i>text</b></i>`,
+			want: `<b><i>text</i></b>`,
+		},
+		{
+			name: "void and self closing elements",
+			html: `line<br>line<br/><p/>`,
+			want: `line<br>line<br><p></p>`,
+		},
+		{
+			name: "comment",
+			html: `a<!-- <script>alert(1)</script> -->b`,
+			want: `ab`,
+		},
+		{
+			name: "unterminated comment",
+			html: `a<!-- <script>alert(1)</script>`,
+			want: `a`,
+		},
+		{
+			name: "unterminated script",
+			html: `a<script>alert(1)`,
+			want: `a`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := string(SanitizeHTML(test.html)); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
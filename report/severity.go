@@ -0,0 +1,47 @@
+package report
+
+import "github.com/jenkins-x/ext-spotbugs/findbugs"
+
+// Severity is a five level severity, as used by code quality tools, derived from the priority and rank of a bug
+type Severity int
+
+// Severities, from the least to the most severe
+const (
+	SeverityInfo Severity = iota
+	SeverityMinor
+	SeverityMajor
+	SeverityCritical
+	SeverityBlocker
+)
+
+var severityNames = []string{"info", "minor", "major", "critical", "blocker"}
+
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+// SeverityOf returns the severity of a bug of the given priority and rank. The rank sets the severity, from critical
+// for the scariest bugs (rank 1 to 4) down to info for those of concern (rank 15 to 20). A high priority then raises it
+// by a level, a low or experimental one lowers it. Bugs without a rank start from minor.
+func SeverityOf(priority, rank int) Severity {
+	var severity Severity
+	switch {
+	case rank <= 0:
+		severity = SeverityMinor
+	case rank <= 4:
+		severity = SeverityCritical
+	case rank <= 9:
+		severity = SeverityMajor
+	case rank <= 14:
+		severity = SeverityMinor
+	default:
+		severity = SeverityInfo
+	}
+	switch {
+	case priority == findbugs.PriorityHigh && severity < SeverityBlocker:
+		severity++
+	case priority > findbugs.PriorityNormal && severity > SeverityInfo:
+		severity--
+	}
+	return severity
+}
@@ -0,0 +1,126 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+// SonarEngineID is the engineId of the issues written by WriteSonar
+const SonarEngineID = "spotbugs"
+
+// sonarVulnerabilityCategories are the categories whose bugs are imported into SonarQube as vulnerabilities
+var sonarVulnerabilityCategories = map[string]bool{
+	"MALICIOUS_CODE": true,
+	"SECURITY":       true,
+}
+
+// DefaultSonarEffort is the estimated effort, in minutes, to fix a bug of each category, used for patterns without an
+// estimate of their own. Categories which aren't listed take 10 minutes.
+var DefaultSonarEffort = map[string]int{
+	"BAD_PRACTICE":   10,
+	"CORRECTNESS":    15,
+	"EXPERIMENTAL":   15,
+	"I18N":           5,
+	"MALICIOUS_CODE": 15,
+	"MT_CORRECTNESS": 30,
+	"PERFORMANCE":    10,
+	"SECURITY":       30,
+	"STYLE":          5,
+}
+
+// SonarOptions configures WriteSonar
+type SonarOptions struct {
+	// SourceRoot is the directory, relative to the root of the project, the source paths of the report are relative to
+	SourceRoot string
+	// Effort is the estimated effort, in minutes, to fix a bug of a pattern, by pattern. Patterns which aren't listed
+	// use DefaultSonarEffort.
+	Effort map[string]int
+}
+
+// SonarIssues is the generic issue import format of SonarQube
+type SonarIssues struct {
+	Issues []SonarIssue `json:"issues"`
+}
+
+// SonarIssue is an issue of the SonarQube generic issue import format
+type SonarIssue struct {
+	EngineID        string        `json:"engineId"`
+	RuleID          string        `json:"ruleId"`
+	Severity        string        `json:"severity"`
+	Type            string        `json:"type"`
+	PrimaryLocation SonarLocation `json:"primaryLocation"`
+	EffortMinutes   int           `json:"effortMinutes,omitempty"`
+}
+
+// SonarLocation is the location of a SonarIssue
+type SonarLocation struct {
+	Message   string          `json:"message"`
+	FilePath  string          `json:"filePath"`
+	TextRange *SonarTextRange `json:"textRange,omitempty"`
+}
+
+// SonarTextRange are the lines of a SonarLocation
+type SonarTextRange struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine,omitempty"`
+}
+
+// NewSonarIssue converts b to a SonarQube issue. Its severity is derived from the priority and rank of b, see
+// SeverityOf, and it is a vulnerability if b is in a security related category, otherwise a bug.
+func NewSonarIssue(b findbugs.BugInstance, options SonarOptions) SonarIssue {
+	f := NewFinding(b)
+	issueType := "BUG"
+	if sonarVulnerabilityCategories[b.Category] {
+		issueType = "VULNERABILITY"
+	}
+	filePath := f.File
+	if filePath == "" {
+		// SonarQube drops issues of files it doesn't know; the conventional source file of the class is the best guess
+		filePath = classSourcePath(b.Class.ClassName)
+	}
+	issue := SonarIssue{
+		EngineID: SonarEngineID,
+		RuleID:   b.Type,
+		Severity: strings.ToUpper(SeverityOf(b.Priority, b.Rank).String()),
+		Type:     issueType,
+		PrimaryLocation: SonarLocation{
+			Message:  f.Message,
+			FilePath: path.Join(options.SourceRoot, filePath),
+		},
+		EffortMinutes: options.effort(b),
+	}
+	// SonarQube rejects a text range with an end before its start
+	if location := b.Location(); location.Start > 0 {
+		issue.PrimaryLocation.TextRange = &SonarTextRange{StartLine: location.Start}
+		if location.End >= location.Start {
+			issue.PrimaryLocation.TextRange.EndLine = location.End
+		}
+	}
+	return issue
+}
+
+func (o SonarOptions) effort(b findbugs.BugInstance) int {
+	if minutes, ok := o.Effort[b.Type]; ok {
+		return minutes
+	}
+	if minutes, ok := DefaultSonarEffort[b.Category]; ok {
+		return minutes
+	}
+	return 10
+}
+
+// WriteSonar writes the bugs of bugCollection in the generic issue import format of SonarQube, so the results of the
+// pipeline can be imported with the sonar.externalIssuesReportPaths property
+func WriteSonar(w io.Writer, bugCollection findbugs.BugCollection, options SonarOptions) error {
+	result := SonarIssues{Issues: make([]SonarIssue, 0, len(bugCollection.BugInstance))}
+	for _, b := range bugCollection.BugInstance {
+		result.Issues = append(result.Issues, NewSonarIssue(b, options))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
@@ -0,0 +1,42 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+func TestSonarIssuePath(t *testing.T) {
+	tests := []struct {
+		name string
+		bug  findbugs.BugInstance
+		want string
+	}{
+		{
+			name: "source path",
+			bug: findbugs.BugInstance{
+				Class:      findbugs.Class{ClassName: "com.example.Dao"},
+				SourceLine: findbugs.SourceLine{SourcePath: "com/example/Dao.java", Start: 12},
+			},
+			want: "src/main/java/com/example/Dao.java",
+		},
+		{
+			name: "no source path",
+			bug:  findbugs.BugInstance{Class: findbugs.Class{ClassName: "com.example.Dao"}},
+			want: "src/main/java/com/example/Dao.java",
+		},
+		{
+			name: "no source path for an inner class",
+			bug:  findbugs.BugInstance{Class: findbugs.Class{ClassName: "com.example.Dao$Row"}},
+			want: "src/main/java/com/example/Dao.java",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			issue := NewSonarIssue(test.bug, SonarOptions{SourceRoot: "src/main/java"})
+			if got := issue.PrimaryLocation.FilePath; got != test.want {
+				t.Errorf("got path %s, want %s", got, test.want)
+			}
+		})
+	}
+}
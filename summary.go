@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	jenkinsv1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+// analysisSummary is the summary written to a PipelineActivity. jenkinsv1.StaticProgramAnalysis has no field for bugs
+// of experimental priority, so they are counted alongside it and written as the tags priority:experimental=<count>
+// and priority:experimental:<category>=<count>, as fields the PipelineActivity CRD doesn't define may be dropped
+type analysisSummary struct {
+	jenkinsv1.StaticProgramAnalysis
+	ExperimentalPriority int
+	// CategoryExperimentalPriority counts the bugs of experimental priority by category
+	CategoryExperimentalPriority map[string]int
+	// Raw are the totals of the report before filters were applied, nil if it wasn't filtered. The CRD has no field
+	// for them, so they are written to the annotation annotationRawTotals
+	Raw *rawTotals
+}
+
+// rawTotals are the totals of a report before filters were applied
+type rawTotals struct {
+	TotalBugs            int `json:"totalBugs"`
+	HighPriority         int `json:"highPriority"`
+	NormalPriority       int `json:"normalPriority"`
+	LowPriority          int `json:"lowPriority"`
+	ExperimentalPriority int `json:"experimentalPriority"`
+	Ignored              int `json:"ignored"`
+}
+
+// MarshalJSON writes the summary the way it is stored on the PipelineActivity
+func (s analysisSummary) MarshalJSON() ([]byte, error) {
+	result, err := s.jsonMap()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(result)
+}
+
+func (s analysisSummary) jsonMap() (map[string]interface{}, error) {
+	result, err := toJSONMap(s.StaticProgramAnalysis)
+	if err != nil {
+		return nil, err
+	}
+	result["original"] = originalJSON(s.Original)
+	return result, nil
+}
+
+const (
+	// toolName tags summaries and reports with the tool which produced them
+	toolName = "spotbugs"
+	// reportMimeType is the mime type of SpotBugs XML reports
+	reportMimeType = "application/xml"
+)
+
+// summarise creates the summary of bugCollection written to the PipelineActivity. Bugs are counted under the priority
+// config.priorityOf returns. The tags of the summary record the tool and its settings, the number of bugs of
+// experimental priority, in total and by category, the number of bugs in each rank bucket, the basis and the
+// completeness of the analysis. The outcome of the quality gate is tagged by gated
+func summarise(bugCollection findbugs.BugCollection, config analysisConfig) analysisSummary {
+	// Create the summaries for the categories
+	categories := make(map[string]jenkinsv1.StaticProgramAnalysisCategory)
+	experimental := make(map[string]int)
+	total := jenkinsv1.StaticProgramAnalysisCategory{}
+	totalExperimental := 0
+	buckets := make(map[string]int)
+	for _, b := range bugCollection.BugInstance {
+		category, ok := categories[b.Category]
+		if !ok {
+			category = jenkinsv1.StaticProgramAnalysisCategory{}
+		}
+		priority := config.priorityOf(b)
+		count(&category, priority)
+		count(&total, priority)
+		if priority == findbugs.PriorityExperimental {
+			experimental[b.Category]++
+			totalExperimental++
+		}
+		categories[b.Category] = category
+		if bucket := findbugs.RankBucket(b.Rank); bucket != "" {
+			buckets[bucket]++
+		}
+	}
+	summary := analysisSummary{
+		StaticProgramAnalysis: jenkinsv1.StaticProgramAnalysis{
+			TotalBugs:      bugCollection.FindBugsSummary.TotalBugs,
+			HighPriority:   bugCollection.FindBugsSummary.HighPriority,
+			NormalPriority: bugCollection.FindBugsSummary.NormalPriority,
+			LowPriority:    bugCollection.FindBugsSummary.LowPriority,
+			Ignored:        bugCollection.FindBugsSummary.IgnorePriority,
+			TotalClasses:   bugCollection.FindBugsSummary.TotalClasses,
+			Categories:     categories,
+		},
+		ExperimentalPriority:         bugCollection.FindBugsSummary.ExpPriority,
+		CategoryExperimentalPriority: experimental,
+	}
+	if config.Basis != basisPriority {
+		// The totals of FindBugsSummary are by priority, so they are replaced by the counts based on rank
+		summary.HighPriority = total.HighPriority
+		summary.NormalPriority = total.NormalPriority
+		summary.LowPriority = total.LowPriority
+		summary.Ignored = total.Ignored
+		summary.ExperimentalPriority = totalExperimental
+	}
+	summary.Tags = append(summary.Tags, "tool:"+toolName)
+	if config.Effort != "" {
+		summary.Tags = append(summary.Tags, "effort:"+config.Effort)
+	}
+	if config.Threshold != "" {
+		summary.Tags = append(summary.Tags, "threshold:"+config.Threshold)
+	}
+	summary.Tags = append(summary.Tags, "basis:"+config.Basis)
+	summary.Tags = append(summary.Tags, experimentalTags(summary)...)
+	for _, bucket := range findbugs.RankBuckets {
+		summary.Tags = append(summary.Tags, fmt.Sprintf("rank:%s=%d", bucket, buckets[bucket]))
+	}
+	completeness := checkCompleteness(bugCollection)
+	summary.Tags = append(summary.Tags, "analysis:"+completeness.status())
+	if !completeness.complete() {
+		summary.Tags = append(summary.Tags,
+			fmt.Sprintf("analysis:missing-classes=%d", completeness.MissingClasses),
+			fmt.Sprintf("analysis:errors=%d", completeness.Errors))
+	}
+	return summary
+}
+
+// gated tags the summary with the outcome of the quality gate, if one is configured
+func (s *analysisSummary) gated(result gateResult) {
+	if status := result.status(); status != "" {
+		s.Tags = append(s.Tags, "quality-gate:"+status)
+	}
+}
+
+// experimentalTags returns the tags recording the bugs of experimental priority of summary, in total and for every
+// category which has any
+func experimentalTags(summary analysisSummary) []string {
+	tags := []string{fmt.Sprintf("priority:experimental=%d", summary.ExperimentalPriority)}
+	names := make([]string, 0, len(summary.CategoryExperimentalPriority))
+	for name, n := range summary.CategoryExperimentalPriority {
+		if n > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		tags = append(tags, fmt.Sprintf("priority:experimental:%s=%d", name, summary.CategoryExperimentalPriority[name]))
+	}
+	return tags
+}
+
+// totals returns the totals of the summary
+func (s analysisSummary) totals() rawTotals {
+	return rawTotals{
+		TotalBugs:            s.TotalBugs,
+		HighPriority:         s.HighPriority,
+		NormalPriority:       s.NormalPriority,
+		LowPriority:          s.LowPriority,
+		ExperimentalPriority: s.ExperimentalPriority,
+		Ignored:              s.Ignored,
+	}
+}
+
+// filtered records that the summary was computed from a filtered report, whose summary before filtering is raw. The
+// totals of raw are kept and the number of bugs the filters suppressed is tagged
+func (s *analysisSummary) filtered(raw analysisSummary) {
+	totals := raw.totals()
+	s.Raw = &totals
+	s.Tags = append(s.Tags, fmt.Sprintf("filter:suppressed=%d", raw.TotalBugs-s.TotalBugs))
+}
+
+// rawAnnotation returns the value of annotationRawTotals, the totals before filtering as JSON. It is empty if the
+// report wasn't filtered, which removes the annotation
+func (s analysisSummary) rawAnnotation() string {
+	if s.Raw == nil {
+		return ""
+	}
+	data, _ := json.Marshal(s.Raw)
+	return string(data)
+}
+
+// originalReport describes the SpotBugs report a summary of bugCollection is computed from
+func originalReport(bugCollection findbugs.BugCollection) jenkinsv1.Original {
+	tags := []string{toolName}
+	if bugCollection.Version != "" {
+		tags = append(tags, "version:"+bugCollection.Version)
+	}
+	if javaVersion := bugCollection.FindBugsSummary.JavaVersion; javaVersion != "" {
+		tags = append(tags, "java:"+javaVersion)
+	}
+	return jenkinsv1.Original{
+		MimeType: reportMimeType,
+		Tags:     tags,
+	}
+}
+
+// count adds a bug of priority to category. Bugs of experimental priority are counted by the caller, as
+// jenkinsv1.StaticProgramAnalysisCategory has no field for them
+func count(category *jenkinsv1.StaticProgramAnalysisCategory, priority int) {
+	switch priority {
+	case findbugs.PriorityHigh:
+		category.HighPriority++
+	case findbugs.PriorityNormal:
+		category.NormalPriority++
+	case findbugs.PriorityLow:
+		category.LowPriority++
+	case findbugs.PriorityIgnore:
+		category.Ignored++
+	}
+}
+
+// checkTotals compares the bugs of bugCollection with the totals SpotBugs reported in its FindBugsSummary, returning a
+// description of every difference. The totals of the summary are taken from FindBugsSummary, so a difference means
+// they don't add up to the counts by category
+func checkTotals(bugCollection findbugs.BugCollection) []string {
+	counted := make(map[int]int)
+	for _, b := range bugCollection.BugInstance {
+		counted[b.Priority]++
+	}
+	reported := bugCollection.FindBugsSummary
+	var warnings []string
+	if len(bugCollection.BugInstance) != reported.TotalBugs {
+		warnings = append(warnings, fmt.Sprintf("the report contains %d bugs but its summary has a total of %d",
+			len(bugCollection.BugInstance), reported.TotalBugs))
+	}
+	totals := map[int]int{
+		findbugs.PriorityHigh:         reported.HighPriority,
+		findbugs.PriorityNormal:       reported.NormalPriority,
+		findbugs.PriorityLow:          reported.LowPriority,
+		findbugs.PriorityExperimental: reported.ExpPriority,
+		findbugs.PriorityIgnore:       reported.IgnorePriority,
+	}
+	for priority := findbugs.PriorityHigh; priority <= findbugs.PriorityIgnore; priority++ {
+		if counted[priority] != totals[priority] {
+			warnings = append(warnings, fmt.Sprintf("the report contains %d bugs of %s priority but its summary has %d",
+				counted[priority], findbugs.PriorityName(priority), totals[priority]))
+		}
+	}
+	return warnings
+}
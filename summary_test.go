@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/ext-spotbugs/findbugs"
+)
+
+// crdFields are the fields the PipelineActivity CRD defines for spec.summaries.staticProgramAnalysis and the objects
+// nested in it, by their path. The keys of categories are the names of the categories. Other fields may be dropped by
+// the API server
+var crdFields = map[string][]string{
+	"": {"categories", "highPriority", "ignored", "lowPriority", "normalPriority", "original", "tags", "totalBugs",
+		"totalClasses"},
+	"categories.*": {"highPriority", "ignored", "lowPriority", "normalPriority"},
+	"original":     {"mimetype", "tags"},
+}
+
+func TestSummarise(t *testing.T) {
+	bugCollection, err := findbugs.ParseFile(sampleReport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := summarise(bugCollection, analysisConfig{Basis: basisPriority})
+	if summary.TotalBugs != 5 || summary.HighPriority != 2 || summary.NormalPriority != 1 || summary.LowPriority != 1 {
+		t.Errorf("got totals %+v", summary.StaticProgramAnalysis)
+	}
+	wantTags := []string{
+		"tool:spotbugs",
+		"basis:priority",
+		"priority:experimental=1",
+		"priority:experimental:PERFORMANCE=1",
+		"rank:scariest=1",
+		"rank:scary=0",
+		"rank:troubling=0",
+		"rank:concern=4",
+		"analysis:incomplete",
+		"analysis:missing-classes=2",
+		"analysis:errors=0",
+	}
+	if !reflect.DeepEqual(summary.Tags, wantTags) {
+		t.Errorf("got tags\n%s\nwant\n%s", strings.Join(summary.Tags, "\n"), strings.Join(wantTags, "\n"))
+	}
+}
+
+func TestSummaryOnlyUsesCRDFields(t *testing.T) {
+	bugCollection, err := findbugs.ParseFile(sampleReport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := summarise(bugCollection, analysisConfig{Basis: basisBoth})
+	summary.filtered(summarise(bugCollection, analysisConfig{Basis: basisBoth}))
+	summary.Original = originalReport(bugCollection)
+	data, err := json.Marshal(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored map[string]interface{}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	checkFields(t, "", stored)
+}
+
+// checkFields reports the fields of the object at path, and of the objects nested in it, which the CRD doesn't define
+func checkFields(t *testing.T, path string, object map[string]interface{}) {
+	allowed, known := crdFields[path]
+	for field, value := range object {
+		fieldPath := strings.TrimPrefix(path+"."+field, ".")
+		if path == "categories" {
+			fieldPath = "categories.*"
+		} else if !contains(allowed, field) {
+			t.Errorf("%s has the field %s, which the CRD doesn't define", path, field)
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			checkFields(t, fieldPath, nested)
+		}
+	}
+	if !known && path != "categories" {
+		t.Errorf("%s is an object, which the CRD doesn't define", path)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"sync"
+
+	jenkinsv1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+)
+
+// workQueue processes PipelineActivities on a fixed number of workers, so a slow report doesn't hold up the others. An
+// activity is never processed by two workers at once: events for an activity which is being processed are held back
+// until it is done. Only the latest event for an activity is kept, as it supersedes the earlier ones
+type workQueue struct {
+	process func(act *jenkinsv1.PipelineActivity)
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// ready are the keys of the activities waiting for a worker, in the order they were added
+	ready []string
+	// pending are the latest versions of the activities which haven't been processed yet
+	pending map[string]*jenkinsv1.PipelineActivity
+	// active are the keys of the activities being processed
+	active   map[string]bool
+	shutdown bool
+	workers  sync.WaitGroup
+}
+
+// newWorkQueue starts workers which call process for the activities added to the queue
+func newWorkQueue(workers int, process func(act *jenkinsv1.PipelineActivity)) *workQueue {
+	q := &workQueue{
+		process: process,
+		pending: make(map[string]*jenkinsv1.PipelineActivity),
+		active:  make(map[string]bool),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	q.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+func activityKey(act *jenkinsv1.PipelineActivity) string {
+	return act.Namespace + "/" + act.Name
+}
+
+// add queues act to be processed, replacing a version of it which is still waiting
+func (q *workQueue) add(act *jenkinsv1.PipelineActivity) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	key := activityKey(act)
+	_, waiting := q.pending[key]
+	q.pending[key] = act
+	// An active activity is queued again once it is done
+	if !waiting && !q.active[key] {
+		q.ready = append(q.ready, key)
+		q.cond.Signal()
+	}
+}
+
+// shutDown stops the workers once every activity added has been processed and waits for them
+func (q *workQueue) shutDown() {
+	q.mu.Lock()
+	q.shutdown = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	q.workers.Wait()
+}
+
+func (q *workQueue) work() {
+	defer q.workers.Done()
+	for {
+		q.mu.Lock()
+		for len(q.ready) == 0 && !(q.shutdown && len(q.active) == 0) {
+			q.cond.Wait()
+		}
+		if len(q.ready) == 0 {
+			// Shut down and nothing left to do, wake up the other workers so they exit too
+			q.cond.Broadcast()
+			q.mu.Unlock()
+			return
+		}
+		key := q.ready[0]
+		q.ready = q.ready[1:]
+		act := q.pending[key]
+		delete(q.pending, key)
+		q.active[key] = true
+		q.mu.Unlock()
+
+		q.process(act)
+
+		q.mu.Lock()
+		delete(q.active, key)
+		if _, ok := q.pending[key]; ok {
+			q.ready = append(q.ready, key)
+			q.cond.Signal()
+		} else if q.shutdown && len(q.active) == 0 && len(q.ready) == 0 {
+			q.cond.Broadcast()
+		}
+		q.mu.Unlock()
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	jenkinsv1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func queuedActivity(name, version string) *jenkinsv1.PipelineActivity {
+	return &jenkinsv1.PipelineActivity{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "jx", Name: name, ResourceVersion: version},
+	}
+}
+
+// recordingProcess records the activities processed and how many versions of each are processed at once. Processing
+// blocks until release is closed
+type recordingProcess struct {
+	release chan struct{}
+	started chan string
+
+	mu        sync.Mutex
+	processed []string
+	running   map[string]int
+	overlaps  int
+}
+
+func newRecordingProcess() *recordingProcess {
+	return &recordingProcess{
+		release: make(chan struct{}),
+		started: make(chan string, 100),
+		running: make(map[string]int),
+	}
+}
+
+func (p *recordingProcess) process(act *jenkinsv1.PipelineActivity) {
+	p.mu.Lock()
+	p.running[act.Name]++
+	if p.running[act.Name] > 1 {
+		p.overlaps++
+	}
+	p.mu.Unlock()
+	p.started <- act.Name + "@" + act.ResourceVersion
+	<-p.release
+	p.mu.Lock()
+	p.running[act.Name]--
+	p.processed = append(p.processed, act.Name+"@"+act.ResourceVersion)
+	p.mu.Unlock()
+}
+
+func (p *recordingProcess) wait(t *testing.T, want string) {
+	select {
+	case got := <-p.started:
+		if got != want {
+			t.Fatalf("got %s processed, want %s", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s wasn't processed", want)
+	}
+}
+
+func TestWorkQueueProcessesLatestVersionOnce(t *testing.T) {
+	p := newRecordingProcess()
+	queue := newWorkQueue(4, p.process)
+	queue.add(queuedActivity("demo-1", "1"))
+	p.wait(t, "demo-1@1")
+
+	// Versions added while demo-1 is processed wait for it, only the latest of them is kept
+	for _, version := range []string{"2", "3", "4"} {
+		queue.add(queuedActivity("demo-1", version))
+	}
+	queue.add(queuedActivity("demo-2", "1"))
+	p.wait(t, "demo-2@1")
+	select {
+	case got := <-p.started:
+		t.Fatalf("got %s processed while demo-1@1 was processed", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(p.release)
+	p.wait(t, "demo-1@4")
+	queue.shutDown()
+	if p.overlaps != 0 {
+		t.Errorf("got an activity processed by %d workers at once", p.overlaps+1)
+	}
+	if len(p.processed) != 3 {
+		t.Errorf("got %v processed, want demo-1@1, demo-2@1 and demo-1@4", p.processed)
+	}
+}
+
+func TestWorkQueueNeverProcessesAnActivityTwiceAtOnce(t *testing.T) {
+	var mu sync.Mutex
+	running := make(map[string]bool)
+	overlaps := 0
+	queue := newWorkQueue(8, func(act *jenkinsv1.PipelineActivity) {
+		mu.Lock()
+		if running[act.Name] {
+			overlaps++
+		}
+		running[act.Name] = true
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		running[act.Name] = false
+		mu.Unlock()
+	})
+	for i := 0; i < 200; i++ {
+		queue.add(queuedActivity(fmt.Sprintf("demo-%d", i%3), fmt.Sprint(i)))
+		if i%10 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	queue.shutDown()
+	if overlaps != 0 {
+		t.Errorf("got activities processed by two workers at once %d times", overlaps)
+	}
+}
+
+func TestWorkQueueShutDownDrains(t *testing.T) {
+	var mu sync.Mutex
+	processed := make(map[string]bool)
+	queue := newWorkQueue(2, func(act *jenkinsv1.PipelineActivity) {
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		processed[act.Name] = true
+		mu.Unlock()
+	})
+	for i := 0; i < 20; i++ {
+		queue.add(queuedActivity(fmt.Sprintf("demo-%d", i), "1"))
+	}
+	queue.shutDown()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(processed) != 20 {
+		t.Errorf("got %d activities processed when shutDown returned, want all 20", len(processed))
+	}
+}